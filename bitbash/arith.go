@@ -0,0 +1,489 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Variables can hold expressions that refer to other variables, this
+// limits how deep that can go before giving up
+const MAX_ARITH_DEPTH = 64
+
+type arithParser struct {
+	cfg   *Config
+	input string
+	pos   int
+	depth int
+	// Greater than zero while evaluating an operand whose value is not
+	// used, such as the right side of a short-circuited &&
+	skip int
+}
+
+// arithError is an error that already names the expression it occurred
+// in, so the expressions of the variables that led to it do not name
+// theirs as well.
+type arithError struct {
+	msg string
+}
+
+func (e *arithError) Error() string {
+	return e.msg
+}
+
+// EvalArith evaluates the integer expression expr. Variables are referred
+// to by name and their values are themselves evaluated as expressions.
+func (cfg *Config) EvalArith(expr string) (int64, error) {
+	return cfg.evalArith(expr, 0)
+}
+
+func (cfg *Config) evalArith(expr string, depth int) (int64, error) {
+	if depth > MAX_ARITH_DEPTH {
+		return 0, &arithError{fmt.Sprintf("%s: expression recursion level exceeded", strings.TrimSpace(expr))}
+	}
+
+	p := &arithParser{cfg: cfg, input: expr, depth: depth}
+
+	p.skipSpaces()
+	if p.pos == len(p.input) {
+		return 0, nil
+	}
+
+	value, err := p.parseComma()
+	if err == nil && p.pos < len(p.input) {
+		err = p.syntaxError()
+	}
+	if err != nil {
+		var reported *arithError
+		if errors.As(err, &reported) {
+			return 0, err
+		}
+		return 0, &arithError{fmt.Sprintf("%s: %s", strings.TrimSpace(expr), err)}
+	}
+	return value, nil
+}
+
+func (p *arithParser) skipSpaces() {
+	for p.pos < len(p.input) && strings.IndexByte(" \t\n", p.input[p.pos]) != -1 {
+		p.pos++
+	}
+}
+
+func (p *arithParser) syntaxError() error {
+	if p.pos >= len(p.input) {
+		return fmt.Errorf("syntax error: operand expected")
+	}
+	return fmt.Errorf("syntax error in expression (error token is %q)", p.input[p.pos:])
+}
+
+// peekOp returns the operator at the current position, or "" if there is
+// none.
+func (p *arithParser) peekOp() string {
+	for _, op := range ARITH_OPS {
+		if strings.HasPrefix(p.input[p.pos:], op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// accept consumes the operator at the current position if it is one of ops.
+func (p *arithParser) accept(ops ...string) (string, bool) {
+	op := p.peekOp()
+	for _, candidate := range ops {
+		if op == candidate {
+			p.pos += len(op)
+			p.skipSpaces()
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *arithParser) parseComma() (int64, error) {
+	value, err := p.parseAssign()
+	for err == nil {
+		if _, ok := p.accept(","); !ok {
+			break
+		}
+		value, err = p.parseAssign()
+	}
+	return value, err
+}
+
+func (p *arithParser) parseAssign() (int64, error) {
+	start := p.pos
+
+	if name := p.readName(); name != "" {
+		if op, ok := p.accept(ARITH_ASSIGNMENT_OPS...); ok {
+			value, err := p.parseAssign()
+			if err != nil {
+				return 0, err
+			}
+
+			if op != "=" {
+				current, err := p.varValue(name)
+				if err != nil {
+					return 0, err
+				}
+				if value, err = p.binary(strings.TrimSuffix(op, "="), current, value); err != nil {
+					return 0, err
+				}
+			}
+
+			return value, p.setVar(name, value)
+		}
+	}
+
+	p.pos = start
+	return p.parseTernary()
+}
+
+func (p *arithParser) parseTernary() (int64, error) {
+	cond, err := p.parseBinary(0)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, ok := p.accept("?"); !ok {
+		return cond, nil
+	}
+
+	if cond == 0 {
+		p.skip++
+	}
+	ifTrue, err := p.parseComma()
+	if cond == 0 {
+		p.skip--
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if _, ok := p.accept(":"); !ok {
+		return 0, p.syntaxError()
+	}
+
+	if cond != 0 {
+		p.skip++
+	}
+	ifFalse, err := p.parseTernary()
+	if cond != 0 {
+		p.skip--
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if cond != 0 {
+		return ifTrue, nil
+	}
+	return ifFalse, nil
+}
+
+func (p *arithParser) parseBinary(level int) (int64, error) {
+	if level == len(ARITH_PRECEDENCE) {
+		return p.parsePower()
+	}
+
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		op, ok := p.accept(ARITH_PRECEDENCE[level]...)
+		if !ok {
+			return left, nil
+		}
+
+		// The right side of && and || is only evaluated when needed
+		shortCircuit := (op == "&&" && left == 0) || (op == "||" && left != 0)
+		if shortCircuit {
+			p.skip++
+		}
+		right, err := p.parseBinary(level + 1)
+		if shortCircuit {
+			p.skip--
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if left, err = p.binary(op, left, right); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// parsePower parses the right associative '**' operator.
+func (p *arithParser) parsePower() (int64, error) {
+	base, err := p.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, ok := p.accept("**"); !ok {
+		return base, nil
+	}
+
+	exponent, err := p.parsePower()
+	if err != nil {
+		return 0, err
+	}
+	return p.binary("**", base, exponent)
+}
+
+func (p *arithParser) parseUnary() (int64, error) {
+	if op, ok := p.accept("++", "--"); ok {
+		name := p.readName()
+		if name == "" {
+			return 0, p.syntaxError()
+		}
+
+		value, err := p.varValue(name)
+		if err != nil {
+			return 0, err
+		}
+		if op == "++" {
+			value++
+		} else {
+			value--
+		}
+		return value, p.setVar(name, value)
+	}
+
+	if op, ok := p.accept("-", "+", "!", "~"); ok {
+		value, err := p.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+
+		switch op {
+		case "-":
+			return -value, nil
+		case "!":
+			return boolToInt(value == 0), nil
+		case "~":
+			return ^value, nil
+		}
+		return value, nil
+	}
+
+	return p.parsePostfix()
+}
+
+func (p *arithParser) parsePostfix() (int64, error) {
+	if _, ok := p.accept("("); ok {
+		value, err := p.parseComma()
+		if err != nil {
+			return 0, err
+		}
+		if _, ok := p.accept(")"); !ok {
+			return 0, fmt.Errorf("missing ')'")
+		}
+		return value, nil
+	}
+
+	if p.pos < len(p.input) && isDigit(p.input[p.pos]) {
+		return p.readNumber()
+	}
+
+	name := p.readName()
+	if name == "" {
+		return 0, p.syntaxError()
+	}
+
+	value, err := p.varValue(name)
+	if err != nil {
+		return 0, err
+	}
+
+	if op, ok := p.accept("++", "--"); ok {
+		next := value + 1
+		if op == "--" {
+			next = value - 1
+		}
+		return value, p.setVar(name, next)
+	}
+
+	return value, nil
+}
+
+func (p *arithParser) readName() string {
+	start := p.pos
+	if p.pos < len(p.input) && isNameStart(p.input[p.pos]) {
+		for p.pos < len(p.input) && isNameChar(p.input[p.pos]) {
+			p.pos++
+		}
+	}
+
+	name := p.input[start:p.pos]
+	p.skipSpaces()
+	return name
+}
+
+func (p *arithParser) readNumber() (int64, error) {
+	start := p.pos
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		if !isNameChar(c) && c != '#' && c != '@' {
+			break
+		}
+		p.pos++
+	}
+
+	text := p.input[start:p.pos]
+	p.skipSpaces()
+	return ParseArithNumber(text)
+}
+
+// ParseArithNumber parses an integer constant, which can be decimal, octal
+// with a leading 0, hexadecimal with a leading 0x or in any base from 2 to
+// 64 written as BASE#DIGITS.
+func ParseArithNumber(text string) (int64, error) {
+	base, digits := 10, text
+
+	if b, d, ok := strings.Cut(text, "#"); ok {
+		n, err := strconv.Atoi(b)
+		if err != nil || n < 2 || n > 64 {
+			return 0, fmt.Errorf("invalid arithmetic base (error token is %q)", text)
+		}
+		base, digits = n, d
+	} else if len(text) > 2 && (text[:2] == "0x" || text[:2] == "0X") {
+		base, digits = 16, text[2:]
+	} else if len(text) > 1 && text[0] == '0' {
+		base, digits = 8, text[1:]
+	}
+
+	if digits == "" {
+		return 0, fmt.Errorf("invalid number (error token is %q)", text)
+	}
+
+	var value int64
+	for i := 0; i < len(digits); i++ {
+		digit := digitValue(digits[i], base)
+		if digit < 0 || digit >= base {
+			return 0, fmt.Errorf("value too great for base (error token is %q)", text)
+		}
+		value = value*int64(base) + int64(digit)
+	}
+
+	return value, nil
+}
+
+// digitValue returns the value of c as a digit. Up to base 36 letters are
+// case insensitive, above that lowercase letters come first followed by
+// uppercase letters, '@' and '_'.
+func digitValue(c byte, base int) int {
+	switch {
+	case isDigit(c):
+		return int(c - '0')
+	case 'a' <= c && c <= 'z':
+		return int(c-'a') + 10
+	case 'A' <= c && c <= 'Z':
+		if base <= 36 {
+			return int(c-'A') + 10
+		}
+		return int(c-'A') + 36
+	case c == '@':
+		return 62
+	case c == '_':
+		return 63
+	}
+	return -1
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func boolToInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// varValue returns the value of the variable name evaluated as an
+// expression, unset and empty variables are 0.
+func (p *arithParser) varValue(name string) (int64, error) {
+	value, _ := p.cfg.GetVar(name)
+	if strings.TrimSpace(value) == "" {
+		return 0, nil
+	}
+	return p.cfg.evalArith(value, p.depth+1)
+}
+
+func (p *arithParser) setVar(name string, value int64) error {
+	if p.skip > 0 {
+		return nil
+	}
+	return p.cfg.SetVar(name, strconv.FormatInt(value, 10))
+}
+
+func (p *arithParser) binary(op string, left, right int64) (int64, error) {
+	switch op {
+	case "||":
+		return boolToInt(left != 0 || right != 0), nil
+	case "&&":
+		return boolToInt(left != 0 && right != 0), nil
+	case "|":
+		return left | right, nil
+	case "^":
+		return left ^ right, nil
+	case "&":
+		return left & right, nil
+	case "==":
+		return boolToInt(left == right), nil
+	case "!=":
+		return boolToInt(left != right), nil
+	case "<=":
+		return boolToInt(left <= right), nil
+	case ">=":
+		return boolToInt(left >= right), nil
+	case "<":
+		return boolToInt(left < right), nil
+	case ">":
+		return boolToInt(left > right), nil
+	case "<<":
+		return left << (uint64(right) & 63), nil
+	case ">>":
+		return left >> (uint64(right) & 63), nil
+	case "+":
+		return left + right, nil
+	case "-":
+		return left - right, nil
+	case "*":
+		return left * right, nil
+	case "/", "%":
+		if right == 0 {
+			// Division by zero is only an error if the result is used
+			if p.skip > 0 {
+				return 0, nil
+			}
+			return 0, fmt.Errorf("division by 0")
+		}
+		if op == "/" {
+			return left / right, nil
+		}
+		return left % right, nil
+	case "**":
+		if right < 0 {
+			return 0, fmt.Errorf("exponent less than 0")
+		}
+		// Exponentiation by squaring, so large exponents take at most 64
+		// steps
+		result := int64(1)
+		for base := left; right > 0; right >>= 1 {
+			if right&1 == 1 {
+				result *= base
+			}
+			base *= base
+		}
+		return result, nil
+	}
+
+	return 0, fmt.Errorf("%s: unknown operator", op)
+}
@@ -0,0 +1,179 @@
+package main
+
+import "testing"
+
+func TestEvalArith(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expr     string
+		expected int64
+	}{
+		{name: "empty", expr: "  ", expected: 0},
+		{name: "precedence", expr: "1 + 2 * 3", expected: 7},
+		{name: "parentheses", expr: "(1 + 2) * 3", expected: 9},
+		{name: "left associative", expr: "10 - 4 - 3", expected: 3},
+		{name: "power is right associative", expr: "2 ** 3 ** 2", expected: 512},
+		{name: "power with large exponent", expr: "1 ** 4000000000000000000 + 3 ** 5", expected: 244},
+		{name: "power of zero", expr: "5 ** 0", expected: 1},
+		{name: "division truncates", expr: "-7 / 2", expected: -3},
+		{name: "remainder", expr: "-7 % 3", expected: -1},
+		{name: "unary operators", expr: "-(-3) + !0 + ~0", expected: 3},
+		{name: "comparison", expr: "3 >= 3 && 2 < 1 || 4 != 5", expected: 1},
+		{name: "bitwise", expr: "6 & 3 | 8 ^ 1", expected: 11},
+		{name: "shift", expr: "1 << 4 >> 2", expected: 4},
+		{name: "ternary", expr: "0 ? 1 : 2 ? 3 : 4", expected: 3},
+		{name: "comma", expr: "1, 2, 3", expected: 3},
+		{name: "variable", expr: "X * 2", expected: 84},
+		{name: "unset variable", expr: "UNSET + 1", expected: 1},
+		{name: "variable holding expression", expr: "EXPR + 1", expected: 85},
+		{name: "octal", expr: "010", expected: 8},
+		{name: "hexadecimal", expr: "0xff + 0X10", expected: 271},
+		{name: "base prefix", expr: "2#101 + 16#Ff + 36#z", expected: 295},
+		{name: "base 64", expr: "64#aA@_", expected: 10*64*64*64 + 36*64*64 + 62*64 + 63},
+		{name: "short circuit skips division", expr: "0 && 1 / 0", expected: 0},
+		{name: "ternary skips division", expr: "1 ? 2 : 1 / 0", expected: 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.SetVar("X", "42")
+			cfg.SetVar("EXPR", "X * 2")
+
+			got, err := cfg.EvalArith(tc.expr)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != tc.expected {
+				t.Fatalf("expected: %d, got: %d", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestEvalArithAssignment(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expr     string
+		expected int64
+		value    string
+	}{
+		{name: "assign", expr: "N = 3 * 4", expected: 12, value: "12"},
+		{name: "compound assign", expr: "N += 2", expected: 7, value: "7"},
+		{name: "shift assign", expr: "N <<= 2", expected: 20, value: "20"},
+		{name: "chained assign", expr: "M = N = 1", expected: 1, value: "1"},
+		{name: "pre increment", expr: "++N", expected: 6, value: "6"},
+		{name: "post increment", expr: "N++", expected: 5, value: "6"},
+		{name: "pre decrement", expr: "--N", expected: 4, value: "4"},
+		{name: "post decrement", expr: "N--", expected: 5, value: "4"},
+		{name: "skipped assignment", expr: "0 && (N = 9)", expected: 0, value: "5"},
+		{name: "skipped increment", expr: "1 || N++", expected: 1, value: "5"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.SetVar("N", "5")
+
+			got, err := cfg.EvalArith(tc.expr)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != tc.expected {
+				t.Fatalf("expected: %d, got: %d", tc.expected, got)
+			}
+			if value, _ := cfg.GetVar("N"); value != tc.value {
+				t.Fatalf("expected N=%s, got N=%s", tc.value, value)
+			}
+		})
+	}
+}
+
+func TestEvalArithErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		expr string
+	}{
+		{name: "division by zero", expr: "1 / 0"},
+		{name: "remainder by zero", expr: "1 % 0"},
+		{name: "missing operand", expr: "2 +"},
+		{name: "missing parenthesis", expr: "(1 + 2"},
+		{name: "trailing tokens", expr: "1 2"},
+		{name: "digit too large for base", expr: "8#9"},
+		{name: "invalid base", expr: "65#1"},
+		{name: "negative exponent", expr: "2 ** -1"},
+		{name: "increment non-variable", expr: "++5"},
+		{name: "assign to readonly", expr: "RO = 2"},
+		{name: "recursive variable", expr: "LOOP"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Variables["RO"] = &Variable{Value: "1", ReadOnly: true}
+			cfg.SetVar("LOOP", "LOOP + 1")
+
+			if _, err := cfg.EvalArith(tc.expr); err == nil {
+				t.Fatalf("expected error evaluating %#v", tc.expr)
+			}
+		})
+	}
+}
+
+func TestEvalArithErrorMessage(t *testing.T) {
+	testCases := []struct {
+		name     string
+		vars     map[string]string
+		expr     string
+		expected string
+	}{
+		{name: "error in expression", expr: "1 +", expected: "1 +: syntax error: operand expected"},
+		{name: "error in variable", vars: map[string]string{"x": "2 / 0"}, expr: "x + 1", expected: "2 / 0: division by 0"},
+		{name: "recursive variable", vars: map[string]string{"a": "a"}, expr: "a", expected: "a: expression recursion level exceeded"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			for name, value := range tc.vars {
+				cfg.SetVar(name, value)
+			}
+
+			_, err := cfg.EvalArith(tc.expr)
+			if err == nil || err.Error() != tc.expected {
+				t.Fatalf("expected error: %#v, got: %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestArithCommands(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "expansion", input: "echo $((1 + 2))", expected: "3"},
+		{name: "nested parentheses", input: "echo $(( (1 + 2) * (3 + 4) ))", expected: "21"},
+		{name: "parameter in expression", input: "N=4; echo $(($N * N))", expected: "16"},
+		{name: "command substitution in expression", input: "echo $(( $(echo 6) / 2 ))", expected: "3"},
+		{name: "in double quotes", input: `echo "n=$((2 ** 4))"`, expected: "n=16"},
+		{name: "assignment persists", input: "echo $((N = 7)) $N", expected: "7 7"},
+		{name: "command true", input: "((2 > 1)) && echo yes", expected: "yes"},
+		{name: "command false", input: "((0)) || echo no", expected: "no"},
+		{name: "command assigns", input: "((N = 3, N *= 2)); echo $N", expected: "6"},
+		{name: "let", input: `let N=5 "M = N * 2"; echo $N $M $?`, expected: "5 10 0"},
+		{name: "let zero fails", input: "let 0; echo $?", expected: "1"},
+		{name: "error status", input: "((1 / 0)); echo $?", expected: "1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newTestConfig()
+
+			if got := runLineOutput(t, cfg, tc.input); got != tc.expected {
+				t.Fatalf("expected: %#v, got: %#v", tc.expected, got)
+			}
+		})
+	}
+}
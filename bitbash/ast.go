@@ -0,0 +1,383 @@
+package main
+
+import "strings"
+
+// List is the root of a parsed command line, a sequence of and-or lists
+// separated by ';' that are executed one after another.
+type List struct {
+	Items []*AndOrList
+}
+
+// AndOrList is a chain of pipelines joined by '&&' or '||'. Operators[i]
+// sits between Pipelines[i] and Pipelines[i+1].
+type AndOrList struct {
+	Pipelines []*PipelineNode
+	Operators []string
+}
+
+// PipelineNode is one or more commands whose stdout is connected to the
+// stdin of the next command with '|'.
+type PipelineNode struct {
+	Commands []CommandNode
+}
+
+// CommandNode is a single command of a pipeline, either a SimpleCommand
+// or a CompoundCommand.
+type CommandNode interface {
+	commandNode()
+}
+
+// CompoundCommand is a command that is executed by the shell itself
+// rather than by running a builtin or a program, such as ((...)).
+type CompoundCommand interface {
+	CommandNode
+	Execute(cfg *Config, stdio *Stdio) int
+}
+
+// SimpleCommand is a command name and its arguments along with any
+// assignments that precede it and the redirections attached to it.
+type SimpleCommand struct {
+	Assignments []*Assignment
+	Words       []*Word
+	Redirects   []*Redirect
+}
+
+// ArithCommand is a ((...)) command, which succeeds when the expression
+// evaluates to a non-zero value.
+type ArithCommand struct {
+	Expr *Word
+}
+
+// CondCommand is a [[ ... ]] command, which succeeds when the conditional
+// expression is true.
+type CondCommand struct {
+	Expr CondExpr
+}
+
+// IfCommand is an if ... fi command. The body of the first clause whose
+// condition succeeds is executed, or Else if none of them do.
+type IfCommand struct {
+	Clauses []*IfClause
+	Else    *List
+}
+
+// IfClause is the condition and body of an `if` or `elif`.
+type IfClause struct {
+	Cond *List
+	Body *List
+}
+
+// WhileCommand is a while or until loop, which runs Body for as long as
+// Cond succeeds, or with Until set for as long as it fails.
+type WhileCommand struct {
+	Until bool
+	Cond  *List
+	Body  *List
+}
+
+// ForCommand is a for ... in loop, which runs Body once for every field
+// that Words expand to with the variable Name set to it. Without `in` the
+// Words are nil and the positional parameters are used instead.
+type ForCommand struct {
+	Name  string
+	Words []*Word
+	Body  *List
+}
+
+// ArithForCommand is a for ((INIT; COND; STEP)) loop, which evaluates
+// Init once and then runs Body and evaluates Step for as long as Cond is
+// non-zero. A nil Cond is always true.
+type ArithForCommand struct {
+	Init *Word
+	Cond *Word
+	Step *Word
+	Body *List
+}
+
+// SelectCommand is a select ... in loop, which prints a numbered menu of
+// the fields that Words expand to and runs Body with the variable Name set
+// to the one chosen, until `break` or the end of the input. Words is nil
+// when the positional parameters are used instead.
+type SelectCommand struct {
+	Name  string
+	Words []*Word
+	Body  *List
+}
+
+// CaseCommand is a case ... esac command, which runs the body of the
+// first item with a pattern that matches Word.
+type CaseCommand struct {
+	Word  *Word
+	Items []*CaseItem
+}
+
+// CaseItem is one `PATTERN|PATTERN) LIST ;;` of a case command. The
+// Terminator ";;" ends the command, ";&" also runs the body of the next
+// item and ";;&" goes on to test the patterns of the next items. It is ""
+// for a last item written without one.
+type CaseItem struct {
+	Patterns   []*Word
+	Body       *List
+	Terminator string
+}
+
+// BraceGroup is a { LIST; } command, which runs the list in the current
+// shell as a single command.
+type BraceGroup struct {
+	Body *List
+}
+
+// FunctionDef is a `NAME() COMMAND` or `function NAME COMMAND` definition.
+// Running it defines the function, after which NAME runs Body with its
+// arguments as the positional parameters. Source is the definition as it
+// was written.
+type FunctionDef struct {
+	Name   string
+	Body   CommandNode
+	Source string
+}
+
+// Redirected is a compound command followed by redirections that apply to
+// all of the commands inside it, as in `while ...; done < file`.
+type Redirected struct {
+	Command   CompoundCommand
+	Redirects []*Redirect
+}
+
+func (*SimpleCommand) commandNode()   {}
+func (*ArithCommand) commandNode()    {}
+func (*CondCommand) commandNode()     {}
+func (*IfCommand) commandNode()       {}
+func (*WhileCommand) commandNode()    {}
+func (*ForCommand) commandNode()      {}
+func (*ArithForCommand) commandNode() {}
+func (*SelectCommand) commandNode()   {}
+func (*CaseCommand) commandNode()     {}
+func (*BraceGroup) commandNode()      {}
+func (*FunctionDef) commandNode()     {}
+func (*Redirected) commandNode()      {}
+
+// CondExpr is an expression inside [[ ... ]].
+type CondExpr interface {
+	condExpr()
+}
+
+// CondLogical joins two expressions with '&&' or '||'.
+type CondLogical struct {
+	Op    string
+	Left  CondExpr
+	Right CondExpr
+}
+
+// CondNot negates an expression with '!'.
+type CondNot struct {
+	Expr CondExpr
+}
+
+// CondUnary is a test such as -f FILE or -z STRING. A lone word is a
+// CondUnary with the -n operator.
+type CondUnary struct {
+	Op      string
+	Operand *Word
+}
+
+// CondBinary is a comparison such as STRING == PATTERN or N -lt M.
+type CondBinary struct {
+	Op    string
+	Left  *Word
+	Right *Word
+}
+
+func (*CondLogical) condExpr() {}
+func (*CondNot) condExpr()     {}
+func (*CondUnary) condExpr()   {}
+func (*CondBinary) condExpr()  {}
+
+// Assignment is a NAME=value word found before the command name.
+type Assignment struct {
+	Name  string
+	Value *Word
+}
+
+// Redirect is a redirection operator applied to the file descriptor Fd
+// and the word naming its target, a file or for the '<&' and '>&'
+// operators another file descriptor or '-' to close Fd. For here-documents
+// the target is the delimiter and for here-strings the text to read.
+type Redirect struct {
+	Fd     int
+	Op     string
+	Target *Word
+	// Body is the text of a here-document, read from the lines that follow
+	// the command
+	Body *Word
+}
+
+// Word is a single shell word made up of parts that keep track of how
+// each piece of the word was quoted.
+type Word struct {
+	Parts []WordPart
+}
+
+type WordPart interface {
+	wordPart()
+}
+
+// Literal is text inside a word. Quoted is true when the text came from
+// single quotes or was escaped with a backslash.
+type Literal struct {
+	Text   string
+	Quoted bool
+}
+
+// DoubleQuoted holds the parts found between a pair of double quotes.
+type DoubleQuoted struct {
+	Parts []WordPart
+}
+
+// ParamExp is a parameter expansion such as $?, $NAME or ${NAME[1]}.
+// Index is the subscript inside the brackets, if any. Op is an operator
+// such as ":-" or "##" applied with the operand Word, and Replace is the
+// replacement string of the "/" operators. Length is true for ${#NAME}.
+type ParamExp struct {
+	Name    string
+	Index   string
+	Length  bool
+	Op      string
+	Word    *Word
+	Replace *Word
+}
+
+// CommandSub is a command substitution, $(...) or `...`, replaced by the
+// output of List. Source is the text of the commands as written.
+type CommandSub struct {
+	Source string
+	List   *List
+}
+
+// ProcessSub is a process substitution, <(...) or >(...), replaced by the
+// path of a pipe connected to the output of List, or with Output set to
+// its input.
+type ProcessSub struct {
+	Output bool
+	Source string
+	List   *List
+}
+
+// ArithExp is an arithmetic expansion, $((...)), replaced by the value of
+// the expression in Expr after its parameters and command substitutions
+// have been expanded.
+type ArithExp struct {
+	Expr *Word
+}
+
+func (*Literal) wordPart()      {}
+func (*DoubleQuoted) wordPart() {}
+func (*ParamExp) wordPart()     {}
+func (*CommandSub) wordPart()   {}
+func (*ProcessSub) wordPart()   {}
+func (*ArithExp) wordPart()     {}
+
+// Split divides the word at each unquoted sep, as the `;` between the
+// expressions of for ((...)).
+func (w *Word) Split(sep byte) []*Word {
+	words := []*Word{{}}
+	for _, part := range w.Parts {
+		literal, ok := part.(*Literal)
+		if !ok || literal.Quoted {
+			last := words[len(words)-1]
+			last.Parts = append(last.Parts, part)
+			continue
+		}
+
+		for i, text := range strings.Split(literal.Text, string(sep)) {
+			if i > 0 {
+				words = append(words, &Word{})
+			}
+			if text != "" {
+				last := words[len(words)-1]
+				last.Parts = append(last.Parts, &Literal{Text: text})
+			}
+		}
+	}
+	return words
+}
+
+// Literal returns the text of the word with all quoting removed.
+func (w *Word) Literal() string {
+	var sb strings.Builder
+	writeLiteral(&sb, w.Parts)
+	return sb.String()
+}
+
+func writeLiteral(sb *strings.Builder, parts []WordPart) {
+	for _, part := range parts {
+		switch p := part.(type) {
+		case *Literal:
+			sb.WriteString(p.Text)
+		case *DoubleQuoted:
+			writeLiteral(sb, p.Parts)
+		case *ParamExp:
+			writeParam(sb, p)
+		case *CommandSub:
+			sb.WriteString("$(" + p.Source + ")")
+		case *ProcessSub:
+			if p.Output {
+				sb.WriteString(">(" + p.Source + ")")
+			} else {
+				sb.WriteString("<(" + p.Source + ")")
+			}
+		case *ArithExp:
+			sb.WriteString("$((" + p.Expr.Literal() + "))")
+		}
+	}
+}
+
+// IsUnquoted reports whether the word is made of a single unquoted
+// literal equal to text.
+func (w *Word) IsUnquoted(text string) bool {
+	if len(w.Parts) != 1 {
+		return false
+	}
+	lit, ok := w.Parts[0].(*Literal)
+	return ok && !lit.Quoted && lit.Text == text
+}
+
+// HasQuotes reports whether any part of the word was quoted.
+func (w *Word) HasQuotes() bool {
+	for _, part := range w.Parts {
+		switch p := part.(type) {
+		case *Literal:
+			if p.Quoted {
+				return true
+			}
+		case *DoubleQuoted:
+			return true
+		}
+	}
+	return false
+}
+
+func writeParam(sb *strings.Builder, p *ParamExp) {
+	if p.Index == "" && !p.Length && p.Op == "" {
+		sb.WriteString("$" + p.Name)
+		return
+	}
+
+	sb.WriteString("${")
+	if p.Length {
+		sb.WriteString("#")
+	}
+	sb.WriteString(p.Name)
+	if p.Index != "" {
+		sb.WriteString("[" + p.Index + "]")
+	}
+	if p.Op != "" {
+		sb.WriteString(p.Op)
+		writeLiteral(sb, p.Word.Parts)
+	}
+	if p.Replace != nil {
+		sb.WriteString("/")
+		writeLiteral(sb, p.Replace.Parts)
+	}
+	sb.WriteString("}")
+}
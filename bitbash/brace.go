@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// braceItem is a single unquoted character of a word or a part of the
+// word, such as quoted text or a parameter expansion, which brace
+// expansion leaves alone.
+type braceItem struct {
+	char rune
+	part WordPart
+}
+
+func (item braceItem) is(c rune) bool {
+	return item.part == nil && item.char == c
+}
+
+// BraceExpand returns the words generated by the brace expressions in
+// word, such as a{b,c}d or x{1..3}, or the word itself if it has none.
+// Only unquoted braces and commas are recognized.
+func BraceExpand(word *Word) []*Word {
+	var items []braceItem
+	for _, part := range word.Parts {
+		lit, ok := part.(*Literal)
+		if !ok || lit.Quoted {
+			items = append(items, braceItem{part: part})
+			continue
+		}
+		for _, c := range lit.Text {
+			items = append(items, braceItem{char: c})
+		}
+	}
+
+	var words []*Word
+	for _, expanded := range expandBraces(items) {
+		words = append(words, braceWord(expanded))
+	}
+	return words
+}
+
+func expandBraces(items []braceItem) [][]braceItem {
+	for start := range items {
+		if !items[start].is('{') {
+			continue
+		}
+
+		end, commas := matchBrace(items, start)
+		if end == -1 {
+			continue
+		}
+
+		var alternatives [][]braceItem
+		if len(commas) > 0 {
+			prev := start
+			for _, comma := range append(commas, end) {
+				alternatives = append(alternatives, items[prev+1:comma])
+				prev = comma
+			}
+		} else if alternatives = braceSequence(items[start+1 : end]); alternatives == nil {
+			// Braces without a comma or a valid sequence, such as {} or
+			// {a}, are left as they are
+			continue
+		}
+
+		prefix := items[:start]
+		suffixes := expandBraces(items[end+1:])
+
+		var res [][]braceItem
+		for _, alternative := range alternatives {
+			for _, expanded := range expandBraces(alternative) {
+				for _, suffix := range suffixes {
+					combined := make([]braceItem, 0, len(prefix)+len(expanded)+len(suffix))
+					combined = append(combined, prefix...)
+					combined = append(combined, expanded...)
+					combined = append(combined, suffix...)
+					res = append(res, combined)
+				}
+			}
+		}
+		return res
+	}
+
+	return [][]braceItem{items}
+}
+
+// matchBrace returns the index of the '}' that closes the brace at start
+// and the indexes of the commas directly inside it, or -1 if it is never
+// closed.
+func matchBrace(items []braceItem, start int) (int, []int) {
+	depth := 0
+	var commas []int
+
+	for i := start; i < len(items); i++ {
+		switch {
+		case items[i].is('{'):
+			depth++
+		case items[i].is('}'):
+			depth--
+			if depth == 0 {
+				return i, commas
+			}
+		case items[i].is(',') && depth == 1:
+			commas = append(commas, i)
+		}
+	}
+
+	return -1, nil
+}
+
+// braceSequence returns the words of a sequence expression X..Y[..INCR],
+// where X and Y are both integers or both single characters, or nil if
+// items is not one.
+func braceSequence(items []braceItem) [][]braceItem {
+	var sb strings.Builder
+	for _, item := range items {
+		if item.part != nil {
+			return nil
+		}
+		sb.WriteRune(item.char)
+	}
+
+	bounds := strings.Split(sb.String(), "..")
+	if len(bounds) != 2 && len(bounds) != 3 {
+		return nil
+	}
+
+	step := 1
+	if len(bounds) == 3 {
+		n, err := strconv.Atoi(bounds[2])
+		if err != nil {
+			return nil
+		}
+		step = max(n, -n, 1)
+	}
+
+	var values []string
+
+	first, err1 := strconv.Atoi(bounds[0])
+	last, err2 := strconv.Atoi(bounds[1])
+
+	switch {
+	case err1 == nil && err2 == nil:
+		// A leading zero on either bound pads every number to the same width
+		width := 0
+		if isZeroPadded(bounds[0]) || isZeroPadded(bounds[1]) {
+			width = max(len(bounds[0]), len(bounds[1]))
+		}
+		for _, n := range braceRange(first, last, step) {
+			values = append(values, fmt.Sprintf("%0*d", width, n))
+		}
+
+	case err1 != nil && err2 != nil && utf8.RuneCountInString(bounds[0]) == 1 && utf8.RuneCountInString(bounds[1]) == 1:
+		firstChar, _ := utf8.DecodeRuneInString(bounds[0])
+		lastChar, _ := utf8.DecodeRuneInString(bounds[1])
+		for _, c := range braceRange(int(firstChar), int(lastChar), step) {
+			values = append(values, string(rune(c)))
+		}
+
+	default:
+		return nil
+	}
+
+	// Each value is kept as a single part so that it is not scanned for
+	// braces again
+	res := make([][]braceItem, 0, len(values))
+	for _, value := range values {
+		res = append(res, []braceItem{{part: &Literal{Text: value}}})
+	}
+	return res
+}
+
+func isZeroPadded(bound string) bool {
+	bound = strings.TrimPrefix(bound, "-")
+	return len(bound) > 1 && bound[0] == '0'
+}
+
+// braceRange returns the values from first to last, counting up or down
+// in steps of step.
+func braceRange(first, last, step int) []int {
+	var values []int
+	if first <= last {
+		for n := first; n <= last; n += step {
+			values = append(values, n)
+		}
+	} else {
+		for n := first; n >= last; n -= step {
+			values = append(values, n)
+		}
+	}
+	return values
+}
+
+// braceWord joins items back into a word, merging consecutive unquoted
+// characters into a single literal.
+func braceWord(items []braceItem) *Word {
+	word := &Word{}
+	var curr strings.Builder
+
+	for _, item := range items {
+		if item.part == nil {
+			curr.WriteRune(item.char)
+			continue
+		}
+		if curr.Len() > 0 {
+			word.Parts = append(word.Parts, &Literal{Text: curr.String()})
+			curr.Reset()
+		}
+		word.Parts = append(word.Parts, item.part)
+	}
+
+	if curr.Len() > 0 {
+		word.Parts = append(word.Parts, &Literal{Text: curr.String()})
+	}
+	return word
+}
@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestBraceExpansion(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "list", input: "echo src/{api,db,web}", expected: "src/api src/db src/web"},
+		{name: "prefix and suffix", input: "echo a{b,c}d", expected: "abd acd"},
+		{name: "nested", input: "echo {x,y{1,2},z}", expected: "x y1 y2 z"},
+		{name: "consecutive", input: "echo {a,b}{1,2}", expected: "a1 a2 b1 b2"},
+		{name: "empty alternative", input: "echo x{,a}", expected: "x xa"},
+		{name: "numeric range", input: "echo {1..5}", expected: "1 2 3 4 5"},
+		{name: "descending range", input: "echo {3..1}", expected: "3 2 1"},
+		{name: "negative range", input: "echo {-2..1}", expected: "-2 -1 0 1"},
+		{name: "range with step", input: "echo {1..10..3}", expected: "1 4 7 10"},
+		{name: "zero padded", input: "echo file{01..03}.txt", expected: "file01.txt file02.txt file03.txt"},
+		{name: "zero padded descending", input: "echo {010..8}", expected: "010 009 008"},
+		{name: "character range", input: "echo {a..e..2}", expected: "a c e"},
+		{name: "empty braces", input: "echo {}", expected: "{}"},
+		{name: "single element", input: "echo {a}", expected: "{a}"},
+		{name: "invalid range", input: "echo {1..a} {a..} {1.5..3}", expected: "{1..a} {a..} {1.5..3}"},
+		{name: "unmatched brace", input: "echo {a,b", expected: "{a,b"},
+		{name: "quoted braces", input: `echo "{a,b}" \{a,b} {a'',b}`, expected: "{a,b} {a,b} a b"},
+		{name: "quoted comma", input: "echo {a',b'}", expected: "{a,b}"},
+		{name: "before parameter expansion", input: `V=x; echo {$V,"y z"}1`, expected: "x1 y z1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newTestConfig()
+
+			if got := runLineOutput(t, cfg, tc.input); got != tc.expected {
+				t.Fatalf("expected: %#v, got: %#v", tc.expected, got)
+			}
+		})
+	}
+}
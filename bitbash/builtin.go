@@ -3,60 +3,89 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"io"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
 )
 
 type BuiltInCommand struct {
 	Name        string
 	Usage       string
 	Description []string
-	Handler     func(cmd *Command, cfg *Config)
+	Handler     func(cmd *Command, cfg *Config) int
 }
 
-func HandlerCd(cmd *Command, cfg *Config) {
+func HandlerCd(cmd *Command, cfg *Config) int {
 	if len(cmd.Args) != 1 {
 		fmt.Fprintf(cmd.err, "cd: expected 1 argument got %d\n", len(cmd.Args))
-		return
+		return 1
 	}
 
 	dir := cmd.Args[0]
+	oldDir, _ := cfg.WorkingDirectory()
 
-	if after, ok := strings.CutPrefix(dir, "~"); ok {
-		dir = cfg.HomeDirectory + after
+	newDir := dir
+	if !filepath.IsAbs(newDir) {
+		newDir = filepath.Join(oldDir, newDir)
 	}
 
-	if err := os.Chdir(dir); err != nil {
+	info, err := os.Stat(newDir)
+	if err != nil || !info.IsDir() || unix.Access(newDir, unix.X_OK) != nil {
 		fmt.Fprintf(cmd.err, "cd: %s: No such file or directory\n", dir)
-		return
+		return 1
+	}
+
+	// Only the shell itself changes the working directory of the process
+	if !cfg.IsSubshell {
+		if err := os.Chdir(newDir); err != nil {
+			fmt.Fprintf(cmd.err, "cd: %s: No such file or directory\n", dir)
+			return 1
+		}
 	}
 
-	cfg.CurrentDirectory, _ = os.Getwd()
+	cfg.CurrentDirectory = newDir
+	cfg.SetVar("OLDPWD", oldDir)
+	cfg.SetVar("PWD", cfg.CurrentDirectory)
+	return 0
 }
 
-func HandlerEcho(cmd *Command, cfg *Config) {
+func HandlerEcho(cmd *Command, cfg *Config) int {
 	fmt.Fprint(cmd.out, strings.Join(cmd.Args, " "), "\n")
+	return 0
 }
 
-func HandlerExit(cmd *Command, cfg *Config) {
-	exitCode := 0
+func HandlerExit(cmd *Command, cfg *Config) int {
+	// With no argument exit with the status of the last command
+	exitCode := cfg.LastStatus
 
 	if len(cmd.Args) > 1 {
 		fmt.Fprintf(cmd.err, "exit: expected 1 argument got %d\n", len(cmd.Args))
-		return
+		return 1
 	}
 
 	if len(cmd.Args) == 1 {
 		num, err := strconv.Atoi(cmd.Args[0])
 		if err != nil {
 			fmt.Fprintf(cmd.err, "exit: invalid exit code '%s'\n", cmd.Args[0])
-			return
+			return 2
 		}
 		exitCode = num
 	}
 
+	// A subshell only stops running commands instead of exiting the shell
+	if cfg.IsSubshell {
+		cfg.Exiting = true
+		cfg.ExitStatus = exitCode
+		return exitCode
+	}
+
 	// Save history before exiting if HISTFILE env variable is defined
 
 	if path, ok := os.LookupEnv("HISTFILE"); ok {
@@ -70,32 +99,39 @@ func HandlerExit(cmd *Command, cfg *Config) {
 	}
 
 	os.Exit(exitCode)
+	return exitCode
 }
 
-func HandlerPwd(cmd *Command, cfg *Config) {
-	workingDir, err := os.Getwd()
+func HandlerPwd(cmd *Command, cfg *Config) int {
+	workingDir, err := cfg.WorkingDirectory()
 	if err != nil {
 		fmt.Fprintf(cmd.err, "pwd: %s\n", err)
-		return
+		return 1
 	}
 	fmt.Fprintf(cmd.out, "%s\n", workingDir)
+	return 0
 }
 
-func HandlerType(cmd *Command, cfg *Config) {
+func HandlerType(cmd *Command, cfg *Config) int {
 	if len(cmd.Args) != 1 {
 		fmt.Fprintf(cmd.err, "exit: expected 1 argument got %d\r", len(cmd.Args))
-		return
+		return 1
 	}
 
 	command := cmd.Args[0]
+	if fn, ok := cfg.Functions[command]; ok {
+		fmt.Fprintf(cmd.out, "%s is a function\n%s\n", command, fn.Source)
+		return 0
+	}
+
 	if _, ok := BUILTIN_CMDS[command]; ok {
 		fmt.Fprintf(cmd.out, "%s is a shell builtin\n", command)
-		return
+		return 0
 	}
 
-	pathEnv := os.Getenv("PATH")
+	pathEnv, _ := cfg.GetVar("PATH")
 	for dir := range strings.SplitSeq(pathEnv, ":") {
-		dirEntries, err := os.ReadDir(dir)
+		dirEntries, err := os.ReadDir(cfg.ResolvePath(dir))
 		if err != nil {
 			continue
 		}
@@ -112,15 +148,16 @@ func HandlerType(cmd *Command, cfg *Config) {
 
 			if isExecutable := info.Mode()&0111 != 0; isExecutable {
 				fmt.Fprintf(cmd.out, "%s is %s\n", command, filepath.Join(dir, command))
-				return
+				return 0
 			}
 		}
 	}
 
 	fmt.Fprintf(cmd.err, "%s: not found\n", command)
+	return 1
 }
 
-func HandlerHelp(cmd *Command, cfg *Config) {
+func HandlerHelp(cmd *Command, cfg *Config) int {
 	fmt.Fprint(cmd.out, "These BitBash commands are defined internally\n\n")
 	fmt.Fprint(cmd.out, "Commands:\n")
 	for _, builtin := range BUILTIN_CMDS {
@@ -131,9 +168,10 @@ func HandlerHelp(cmd *Command, cfg *Config) {
 		fmt.Fprintf(cmd.out, "\n")
 		
 	}
+	return 0
 }
 
-func HandlerHistory(cmd *Command, cfg *Config) {
+func HandlerHistory(cmd *Command, cfg *Config) int {
 	switch len(cmd.Args) {
 	// No arguments, print entire history
 	case 0:
@@ -145,6 +183,7 @@ func HandlerHistory(cmd *Command, cfg *Config) {
 		size, err := strconv.Atoi(cmd.Args[0])
 		if err != nil {
 			fmt.Fprintf(cmd.err, "history: %s: numeric argument required\n", cmd.Args[0])
+			return 1
 		}
 
 		historySize := len(cfg.History)
@@ -156,9 +195,10 @@ func HandlerHistory(cmd *Command, cfg *Config) {
 	case 2:
 		// Load history from file
 		if cmd.Args[0] == "-r" {
-			historyFile, err := os.Open(cmd.Args[1])
+			historyFile, err := os.Open(cfg.ResolvePath(cmd.Args[1]))
 			if err != nil {
 				fmt.Fprintf(cmd.err, "history: could not open history file: %s\r\n", err)
+				return 1
 			}
 			defer historyFile.Close()
 
@@ -166,14 +206,15 @@ func HandlerHistory(cmd *Command, cfg *Config) {
 			for history.Scan() {
 				cfg.History = append(cfg.History, history.Text())
 			}
-			return
+			return 0
 		}
 
 		// Write history to file
 		if cmd.Args[0] == "-w" {
-			historyFile, err := os.Create(cmd.Args[1])
+			historyFile, err := os.Create(cfg.ResolvePath(cmd.Args[1]))
 			if err != nil {
 				fmt.Fprintf(cmd.err, "history: could not create history file: %s\r\n", err)
+				return 1
 			}
 			defer historyFile.Close()
 
@@ -181,14 +222,15 @@ func HandlerHistory(cmd *Command, cfg *Config) {
 				historyFile.Write(fmt.Appendf(nil, "%s\n", entry))
 			}
 
-			return
+			return 0
 		}
 
 		// Append history to file
 		if cmd.Args[0] == "-a" {
-			historyFile, err := os.OpenFile(cmd.Args[1], os.O_WRONLY|os.O_APPEND, 0o666)
+			historyFile, err := os.OpenFile(cfg.ResolvePath(cmd.Args[1]), os.O_WRONLY|os.O_APPEND, 0o666)
 			if err != nil {
 				fmt.Fprintf(cmd.err, "history: could not open history file: %s\r\n", err)
+				return 1
 			}
 			defer historyFile.Close()
 
@@ -197,12 +239,432 @@ func HandlerHistory(cmd *Command, cfg *Config) {
 			}
 			cfg.SavedUpToIndex = len(cfg.History)
 
-			return
+			return 0
 		}
 
 		fmt.Fprintf(cmd.err, "history: %s: invalid argument\n", cmd.Args[0])
+		return 1
 	default:
 		fmt.Fprint(cmd.err, "history: too many arguments\n")
+		return 1
+	}
+
+	return 0
+}
+
+func HandlerSet(cmd *Command, cfg *Config) int {
+	// `set` without arguments lists all variables
+	if len(cmd.Args) == 0 {
+		for _, name := range slices.Sorted(maps.Keys(cfg.Variables)) {
+			fmt.Fprintf(cmd.out, "%s=%s\n", name, QuoteValue(cfg.Variables[name].Value))
+		}
+		return 0
+	}
+
+	for i := 0; i < len(cmd.Args); i++ {
+		flag := cmd.Args[i]
+
+		// The remaining arguments replace the positional parameters
+		if flag == "--" || (!strings.HasPrefix(flag, "-") && !strings.HasPrefix(flag, "+")) {
+			if flag == "--" {
+				i++
+			}
+			cfg.Positional = slices.Clone(cmd.Args[i:])
+			return 0
+		}
+
+		if flag != "-o" && flag != "+o" {
+			for _, letter := range []byte(flag[1:]) {
+				name, ok := SET_FLAGS[letter]
+				if !ok {
+					fmt.Fprintf(cmd.err, "set: %s: invalid option\n", flag)
+					return 2
+				}
+				cfg.Options[name] = flag[0] == '-'
+			}
+			continue
+		}
+
+		// `set -o` without an option name lists all options
+		if i+1 == len(cmd.Args) {
+			printOptions(cmd, cfg, SET_OPTIONS)
+			return 0
+		}
+
+		i++
+		name := cmd.Args[i]
+		if !slices.Contains(SET_OPTIONS, name) {
+			fmt.Fprintf(cmd.err, "set: %s: invalid option name\n", name)
+			return 1
+		}
+		cfg.Options[name] = flag == "-o"
+	}
+
+	return 0
+}
+
+func printOptions(cmd *Command, cfg *Config, names []string) {
+	for _, name := range names {
+		state := "off"
+		if cfg.Options[name] {
+			state = "on"
+		}
+		fmt.Fprintf(cmd.out, "%-15s\t%s\n", name, state)
+	}
+}
+
+func HandlerShopt(cmd *Command, cfg *Config) int {
+	args, flag, quiet := cmd.Args, "", false
+
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		switch args[0] {
+		case "-s", "-u":
+			flag = args[0]
+		case "-q":
+			quiet = true
+		default:
+			fmt.Fprintf(cmd.err, "shopt: %s: invalid option\n", args[0])
+			return 2
+		}
+		args = args[1:]
+	}
+
+	for _, name := range args {
+		if !slices.Contains(SHOPT_OPTIONS, name) {
+			fmt.Fprintf(cmd.err, "shopt: %s: invalid shell option name\n", name)
+			return 1
+		}
+	}
+
+	if flag != "" {
+		for _, name := range args {
+			cfg.Options[name] = flag == "-s"
+		}
+		return 0
+	}
+
+	// Without -s or -u list the options, failing if any of them is off
+	names, status := args, 0
+	if len(names) == 0 {
+		names = SHOPT_OPTIONS
+	}
+	for _, name := range names {
+		if !cfg.Options[name] {
+			status = 1
+		}
+	}
+
+	if !quiet {
+		printOptions(cmd, cfg, names)
+	}
+	return status
+}
+
+func HandlerExport(cmd *Command, cfg *Config) int {
+	args, unexport := cmd.Args, false
+
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		switch args[0] {
+		case "-n":
+			unexport = true
+		case "-p":
+		default:
+			fmt.Fprintf(cmd.err, "export: %s: invalid option\n", args[0])
+			return 2
+		}
+		args = args[1:]
+	}
+
+	if len(args) == 0 {
+		printVariables(cmd, cfg, "-x", func(v *Variable) bool { return v.Exported })
+		return 0
+	}
+
+	return declareVariables(cmd, cfg, "export", args, func(v *Variable) { v.Exported = !unexport })
+}
+
+func HandlerReadonly(cmd *Command, cfg *Config) int {
+	args := cmd.Args
+
+	if len(args) > 0 && args[0] == "-p" {
+		args = args[1:]
+	}
+
+	if len(args) == 0 {
+		printVariables(cmd, cfg, "-r", func(v *Variable) bool { return v.ReadOnly })
+		return 0
+	}
+
+	return declareVariables(cmd, cfg, "readonly", args, func(v *Variable) { v.ReadOnly = true })
+}
+
+func HandlerUnset(cmd *Command, cfg *Config) int {
+	args, status, functions := cmd.Args, 0, false
+
+	if len(args) > 0 && (args[0] == "-v" || args[0] == "-f") {
+		functions = args[0] == "-f"
+		args = args[1:]
+	}
+
+	for _, name := range args {
+		if functions {
+			delete(cfg.Functions, name)
+			continue
+		}
+		if !IsValidName(name) {
+			fmt.Fprintf(cmd.err, "unset: `%s': not a valid identifier\n", name)
+			status = 1
+			continue
+		}
+		if err := cfg.UnsetVar(name); err != nil {
+			fmt.Fprintf(cmd.err, "unset: %s\n", err)
+			status = 1
+		}
+	}
+
+	return status
+}
+
+func HandlerRead(cmd *Command, cfg *Config) int {
+	args, raw := cmd.Args, false
+
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		switch args[0] {
+		case "-r":
+			raw = true
+		case "-p":
+			if len(args) < 2 {
+				fmt.Fprintf(cmd.err, "read: -p: option requires an argument\n")
+				return 2
+			}
+			fmt.Fprint(cmd.err, args[1])
+			args = args[1:]
+		default:
+			fmt.Fprintf(cmd.err, "read: %s: invalid option\n", args[0])
+			return 2
+		}
+		args = args[1:]
+	}
+
+	for _, name := range args {
+		if !IsValidName(name) {
+			fmt.Fprintf(cmd.err, "read: `%s': not a valid identifier\n", name)
+			return 1
+		}
+	}
+
+	line, complete := readInputLine(cmd.in, cfg, raw)
+
+	// Without names the whole line is stored in REPLY, otherwise each name
+	// gets one field and the last name gets the rest of the line
+	if len(args) == 0 {
+		args = []string{"REPLY"}
+	} else {
+		ifs := cfg.IFS()
+		line = trimIFSWhitespace(line, ifs)
+		for _, name := range args[:len(args)-1] {
+			var value string
+			value, line, _ = cutIFSField(line, ifs)
+			if err := cfg.SetVar(name, value); err != nil {
+				fmt.Fprintf(cmd.err, "read: %s\n", err)
+				return 1
+			}
+		}
+		line = strings.TrimRightFunc(line, ifsWhitespace(ifs))
+	}
+
+	if err := cfg.SetVar(args[len(args)-1], line); err != nil {
+		fmt.Fprintf(cmd.err, "read: %s\n", err)
+		return 1
+	}
+
+	if !complete {
+		return 1
+	}
+	return 0
+}
+
+// readInputLine reads a line from in one byte at a time so that nothing
+// after the line is consumed. The buffered reader of the shell is only
+// used when stdin is the terminal it reads command lines from. Unless raw
+// is true a backslash escapes the next character and a backslash before a
+// newline joins the next line. complete is false if the input ended
+// before a newline.
+func readInputLine(in *os.File, cfg *Config, raw bool) (line string, complete bool) {
+	readByte := func() (byte, error) {
+		var buf [1]byte
+		_, err := io.ReadFull(in, buf[:])
+		return buf[0], err
+	}
+	if in == os.Stdin && cfg.StdinReader != nil && term.IsTerminal(int(in.Fd())) {
+		readByte = cfg.StdinReader.ReadByte
+	}
+
+	var sb strings.Builder
+	for {
+		c, err := readByte()
+		if err != nil {
+			return sb.String(), false
+		}
+
+		if c == '\\' && !raw {
+			if c, err = readByte(); err != nil {
+				return sb.String(), false
+			}
+			if c != '\n' {
+				sb.WriteByte(c)
+			}
+			continue
+		}
+
+		if c == '\n' {
+			return sb.String(), true
+		}
+		sb.WriteByte(c)
+	}
+}
+
+func HandlerLet(cmd *Command, cfg *Config) int {
+	if len(cmd.Args) == 0 {
+		fmt.Fprintf(cmd.err, "let: expression expected\n")
+		return 2
+	}
+
+	var value int64
+	for _, arg := range cmd.Args {
+		var err error
+		if value, err = cfg.EvalArith(arg); err != nil {
+			fmt.Fprintf(cmd.err, "let: %s\n", err)
+			return 1
+		}
+	}
+
+	if value == 0 {
+		return 1
+	}
+	return 0
+}
+
+func HandlerLocal(cmd *Command, cfg *Config) int {
+	if len(cfg.Locals) == 0 {
+		fmt.Fprintf(cmd.err, "local: can only be used in a function\n")
+		return 1
+	}
+
+	status := 0
+	for _, arg := range cmd.Args {
+		name, value, hasValue := strings.Cut(arg, "=")
+		if !IsValidName(name) {
+			fmt.Fprintf(cmd.err, "local: `%s': not a valid identifier\n", arg)
+			status = 1
+			continue
+		}
+		if err := cfg.DeclareLocal(name, value, hasValue); err != nil {
+			fmt.Fprintf(cmd.err, "local: %s\n", err)
+			status = 1
+		}
+	}
+
+	return status
+}
+
+func HandlerReturn(cmd *Command, cfg *Config) int {
+	if len(cfg.FuncNames) == 0 {
+		fmt.Fprintf(cmd.err, "return: can only `return' from a function\n")
+		return 2
+	}
+
+	// With no argument return with the status of the last command
+	status := cfg.LastStatus
+
+	if len(cmd.Args) > 1 {
+		fmt.Fprintf(cmd.err, "return: too many arguments\n")
+		return 2
+	}
+
+	if len(cmd.Args) == 1 {
+		n, err := strconv.Atoi(cmd.Args[0])
+		if err != nil {
+			fmt.Fprintf(cmd.err, "return: %s: numeric argument required\n", cmd.Args[0])
+			return 2
+		}
+		status = n
+	}
+
+	cfg.Returning = true
+	cfg.ReturnStatus = status
+	return status
+}
+
+func HandlerBreak(cmd *Command, cfg *Config) int {
+	return loopControl(cmd, cfg, "break", &cfg.Breaking)
+}
+
+func HandlerContinue(cmd *Command, cfg *Config) int {
+	return loopControl(cmd, cfg, "continue", &cfg.Continuing)
+}
+
+// loopControl handles the optional N argument of break and continue,
+// setting counter to the number of enclosing loops to unwind.
+func loopControl(cmd *Command, cfg *Config, builtin string, counter *int) int {
+	n := 1
+	if len(cmd.Args) > 0 {
+		var err error
+		if n, err = strconv.Atoi(cmd.Args[0]); err != nil {
+			fmt.Fprintf(cmd.err, "%s: %s: numeric argument required\n", builtin, cmd.Args[0])
+			return 1
+		}
+		if n < 1 {
+			fmt.Fprintf(cmd.err, "%s: %d: loop count out of range\n", builtin, n)
+			return 1
+		}
+	}
+
+	if cfg.LoopDepth == 0 {
+		fmt.Fprintf(cmd.err, "%s: only meaningful in a `for', `while', or `until' loop\n", builtin)
+		return 0
+	}
+
+	*counter = min(n, cfg.LoopDepth)
+	return 0
+}
+
+// declareVariables handles the NAME[=VALUE] arguments of export and
+// readonly, assigning VALUE if present and then calling apply to mark
+// the variable.
+func declareVariables(cmd *Command, cfg *Config, builtin string, args []string, apply func(v *Variable)) int {
+	status := 0
+
+	for _, arg := range args {
+		name, value, hasValue := strings.Cut(arg, "=")
+		if !IsValidName(name) {
+			fmt.Fprintf(cmd.err, "%s: `%s': not a valid identifier\n", builtin, arg)
+			status = 1
+			continue
+		}
+
+		if hasValue {
+			if err := cfg.SetVar(name, value); err != nil {
+				fmt.Fprintf(cmd.err, "%s: %s\n", builtin, err)
+				status = 1
+				continue
+			}
+		}
+
+		if _, ok := cfg.Variables[name]; !ok {
+			cfg.Variables[name] = &Variable{}
+		}
+		apply(cfg.Variables[name])
+	}
+
+	return status
+}
+
+func printVariables(cmd *Command, cfg *Config, flag string, filter func(v *Variable) bool) {
+	for _, name := range slices.Sorted(maps.Keys(cfg.Variables)) {
+		if variable := cfg.Variables[name]; filter(variable) {
+			fmt.Fprintf(cmd.out, "declare %s %s=%s\n", flag, name, QuoteValue(variable.Value))
+		}
 	}
 }
 
@@ -226,7 +688,7 @@ func init() {
 	BUILTIN_CMDS["type"] = BuiltInCommand{
 		Name:        "type",
 		Usage:       "type COMMAND",
-		Description: []string{"print whether COMMAND is builtin, if not print location of executatable"},
+		Description: []string{"print whether COMMAND is a function or builtin, if not print location of executatable"},
 		Handler:     HandlerType,
 	}
 
@@ -262,4 +724,103 @@ func init() {
 		},
 		Handler: HandlerHistory,
 	}
+
+	BUILTIN_CMDS["set"] = BuiltInCommand{
+		Name:  "set",
+		Usage: "set [(-o|+o) OPTION] [-C|+C] [--] [ARG...]",
+		Description: []string{
+			"turn a shell option on (-o) or off (+o), list all options if none given. Each ARG becomes a positional parameter.",
+			"noclobber: '>' does not overwrite existing files, use '>|' to overwrite them anyway. Same as -C",
+			"pipefail: the status of a pipeline is that of the last command to fail",
+		},
+		Handler: HandlerSet,
+	}
+
+	BUILTIN_CMDS["shopt"] = BuiltInCommand{
+		Name:  "shopt",
+		Usage: "shopt [-s|-u] [-q] [OPTION...]",
+		Description: []string{
+			"turn each OPTION on (-s) or off (-u), list the options and whether they are on if neither given.",
+			"-q: list nothing, only set the status to whether every OPTION is on",
+			"dotglob: patterns match names starting with '.'",
+			"extglob: enable the ?(...), *(...), +(...), @(...) and !(...) patterns",
+			"failglob: a pattern that matches no files is an error",
+			"globstar: '**' matches files and any number of directories",
+			"nullglob: a pattern that matches no files is removed",
+		},
+		Handler: HandlerShopt,
+	}
+
+	BUILTIN_CMDS["export"] = BuiltInCommand{
+		Name:  "export",
+		Usage: "export [-n] [NAME[=VALUE]...]",
+		Description: []string{
+			"mark each NAME to be passed to child processes in the environment, list exported variables if none given.",
+			"-n: remove the export property from each NAME",
+		},
+		Handler: HandlerExport,
+	}
+
+	BUILTIN_CMDS["readonly"] = BuiltInCommand{
+		Name:        "readonly",
+		Usage:       "readonly [NAME[=VALUE]...]",
+		Description: []string{"mark each NAME as read-only, list read-only variables if none given"},
+		Handler:     HandlerReadonly,
+	}
+
+	BUILTIN_CMDS["unset"] = BuiltInCommand{
+		Name:  "unset",
+		Usage: "unset [-v|-f] NAME...",
+		Description: []string{
+			"remove each NAME from the shell variables.",
+			"-f: remove each function NAME instead",
+		},
+		Handler: HandlerUnset,
+	}
+
+	BUILTIN_CMDS["read"] = BuiltInCommand{
+		Name:  "read",
+		Usage: "read [-r] [-p PROMPT] [NAME...]",
+		Description: []string{
+			"read a line from stdin and split it into fields with IFS, the last NAME gets the rest of the line. Default NAME is REPLY.",
+			"-r: do not treat backslashes as escape characters",
+			"-p: print PROMPT to stderr before reading",
+		},
+		Handler: HandlerRead,
+	}
+
+	BUILTIN_CMDS["let"] = BuiltInCommand{
+		Name:        "let",
+		Usage:       "let EXPR...",
+		Description: []string{"evaluate each arithmetic EXPR, fail if the last one is 0"},
+		Handler:     HandlerLet,
+	}
+
+	BUILTIN_CMDS["break"] = BuiltInCommand{
+		Name:        "break",
+		Usage:       "break [N]",
+		Description: []string{"exit from the innermost loop, or from N enclosing loops"},
+		Handler:     HandlerBreak,
+	}
+
+	BUILTIN_CMDS["local"] = BuiltInCommand{
+		Name:        "local",
+		Usage:       "local [NAME[=VALUE]...]",
+		Description: []string{"make each NAME a variable of the running function, hiding any variable of the same name until it returns"},
+		Handler:     HandlerLocal,
+	}
+
+	BUILTIN_CMDS["return"] = BuiltInCommand{
+		Name:        "return",
+		Usage:       "return [N]",
+		Description: []string{"return from the running function with status N, default is the status of the last command"},
+		Handler:     HandlerReturn,
+	}
+
+	BUILTIN_CMDS["continue"] = BuiltInCommand{
+		Name:        "continue",
+		Usage:       "continue [N]",
+		Description: []string{"skip to the next iteration of the innermost loop, or of the Nth enclosing loop"},
+		Handler:     HandlerContinue,
+	}
 }
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Execute evaluates the conditional expression and returns 0 if it is
+// true, 1 if it is false and 2 if it could not be evaluated.
+func (cond *CondCommand) Execute(cfg *Config, stdio *Stdio) int {
+	ok, err := cfg.evalCond(cond.Expr, stdio)
+	if err != nil {
+		fmt.Fprintf(stdio.Err, "[[: %s\n", err)
+		return 2
+	}
+
+	if ok {
+		return 0
+	}
+	return 1
+}
+
+func (cfg *Config) evalCond(expr CondExpr, stdio *Stdio) (bool, error) {
+	switch e := expr.(type) {
+	case *CondLogical:
+		left, err := cfg.evalCond(e.Left, stdio)
+		if err != nil || left != (e.Op == "&&") {
+			return left, err
+		}
+		return cfg.evalCond(e.Right, stdio)
+
+	case *CondNot:
+		ok, err := cfg.evalCond(e.Expr, stdio)
+		return !ok, err
+
+	case *CondUnary:
+		operand, err := cfg.ExpandWord(cfg.TildeExpand(e.Operand, false), stdio)
+		if err != nil {
+			return false, err
+		}
+		return cfg.testUnary(e.Op, operand), nil
+
+	case *CondBinary:
+		left, err := cfg.ExpandWord(cfg.TildeExpand(e.Left, false), stdio)
+		if err != nil {
+			return false, err
+		}
+
+		// The right side of == and != is a pattern, where only the quoted
+		// parts are matched literally
+		if e.Op == "==" || e.Op == "=" || e.Op == "!=" {
+			pattern, err := cfg.ExpandPattern(cfg.TildeExpand(e.Right, false), stdio)
+			if err != nil {
+				return false, err
+			}
+			return CompilePattern(pattern, cfg.Options["extglob"]).Match(left) == (e.Op != "!="), nil
+		}
+
+		right, err := cfg.ExpandWord(cfg.TildeExpand(e.Right, false), stdio)
+		if err != nil {
+			return false, err
+		}
+		return cfg.testBinary(e.Op, left, right)
+	}
+
+	return false, fmt.Errorf("unknown expression")
+}
+
+func (cfg *Config) testUnary(op, operand string) bool {
+	switch op {
+	case "-n":
+		return operand != ""
+	case "-z":
+		return operand == ""
+	case "-v":
+		_, ok := cfg.GetVar(operand)
+		return ok
+	}
+
+	// The remaining operators test the file named by operand
+	path := cfg.ResolvePath(operand)
+
+	switch op {
+	case "-r":
+		return unix.Access(path, unix.R_OK) == nil
+	case "-w":
+		return unix.Access(path, unix.W_OK) == nil
+	case "-x":
+		return unix.Access(path, unix.X_OK) == nil
+	case "-h", "-L":
+		info, err := os.Lstat(path)
+		return err == nil && info.Mode()&os.ModeSymlink != 0
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+
+	switch op {
+	case "-d":
+		return info.IsDir()
+	case "-f":
+		return info.Mode().IsRegular()
+	case "-s":
+		return info.Size() > 0
+	}
+	return true
+}
+
+// testBinary compares strings with '<' and '>' and integers, which can be
+// arithmetic expressions, with the other operators.
+func (cfg *Config) testBinary(op, left, right string) (bool, error) {
+	switch op {
+	case "<":
+		return left < right, nil
+	case ">":
+		return left > right, nil
+	}
+
+	l, err := cfg.EvalArith(left)
+	if err != nil {
+		return false, err
+	}
+	r, err := cfg.EvalArith(right)
+	if err != nil {
+		return false, err
+	}
+
+	switch op {
+	case "-eq":
+		return l == r, nil
+	case "-ne":
+		return l != r, nil
+	case "-lt":
+		return l < r, nil
+	case "-le":
+		return l <= r, nil
+	case "-gt":
+		return l > r, nil
+	case "-ge":
+		return l >= r, nil
+	}
+	return false, fmt.Errorf("%s: unknown operator", op)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCondCommand(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	if err := os.WriteFile(file, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		input    string
+		expected int
+	}{
+		{name: "non-empty word", input: "[[ word ]]", expected: 0},
+		{name: "empty word", input: "[[ '' ]]", expected: 1},
+		{name: "operator as word", input: "[[ -f ]]", expected: 0},
+		{name: "string empty", input: "[[ -z $UNSET ]]", expected: 0},
+		{name: "string not empty", input: "[[ -n $UNSET ]]", expected: 1},
+		{name: "pattern match", input: "[[ main.go == *.go ]]", expected: 0},
+		{name: "quoted pattern is literal", input: `[[ main.go == "*.go" ]]`, expected: 1},
+		{name: "pattern from variable", input: "P='m*'; [[ main == $P ]]", expected: 0},
+		{name: "pattern mismatch", input: "[[ main.go != *.go ]]", expected: 1},
+		{name: "no word splitting", input: "X='a b'; [[ $X == 'a b' ]]", expected: 0},
+		{name: "extglob pattern", input: "shopt -s extglob; [[ foo.md == @(*.txt|*.md) ]]", expected: 0},
+		{name: "string order", input: "[[ apple < banana ]]", expected: 0},
+		{name: "integer comparison", input: "[[ 10 -gt 9 ]]", expected: 0},
+		{name: "arithmetic operands", input: "N=3; [[ N+1 -eq 4 ]]", expected: 0},
+		{name: "file exists", input: "[[ -e " + file + " ]]", expected: 0},
+		{name: "regular file", input: "[[ -f " + file + " ]]", expected: 0},
+		{name: "directory", input: "[[ -d " + dir + " && ! -d " + file + " ]]", expected: 0},
+		{name: "non-empty file", input: "[[ -s " + file + " ]]", expected: 0},
+		{name: "missing file", input: "[[ -e " + filepath.Join(dir, "missing") + " ]]", expected: 1},
+		{name: "variable set", input: "V=; [[ -v V ]]", expected: 0},
+		{name: "and", input: "[[ a == a && b == c ]]", expected: 1},
+		{name: "or", input: "[[ a == b || b == b ]]", expected: 0},
+		{name: "grouping", input: "[[ ! ( a == b || b == c ) ]]", expected: 0},
+		{name: "invalid integer", input: "[[ 1 -eq 1/0 ]]", expected: 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newTestConfig()
+
+			if status := runLine(t, cfg, tc.input); status != tc.expected {
+				t.Fatalf("expected status: %d, got: %d", tc.expected, status)
+			}
+		})
+	}
+}
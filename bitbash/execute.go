@@ -0,0 +1,477 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+func (list *List) Execute(cfg *Config, stdio *Stdio) int {
+	status := 0
+	for _, item := range list.Items {
+		if cfg.Unwinding() {
+			break
+		}
+		status = item.Execute(cfg, stdio)
+	}
+	return status
+}
+
+// Execute runs the first pipeline and then each following pipeline only
+// if the status so far allows it: '&&' needs success and '||' needs failure.
+func (andOr *AndOrList) Execute(cfg *Config, stdio *Stdio) int {
+	status := NewPipeline(andOr.Pipelines[0], cfg, stdio).Execute(cfg)
+
+	for i, op := range andOr.Operators {
+		if cfg.Unwinding() {
+			break
+		}
+		if (op == "&&") == (status == 0) {
+			status = NewPipeline(andOr.Pipelines[i+1], cfg, stdio).Execute(cfg)
+		}
+	}
+
+	return status
+}
+
+// Execute evaluates the expression and returns 0 if its value is non-zero
+// and 1 otherwise, or if it could not be evaluated.
+func (arith *ArithCommand) Execute(cfg *Config, stdio *Stdio) int {
+	value, err := cfg.ExpandArith(arith.Expr, stdio)
+	if err != nil {
+		fmt.Fprintf(stdio.Err, "((: %s\n", err)
+		return 1
+	}
+
+	if value == 0 {
+		return 1
+	}
+	return 0
+}
+
+// Execute runs the body of the first clause whose condition succeeds, or
+// the else branch if there is one. Without either the status is 0.
+func (cmd *IfCommand) Execute(cfg *Config, stdio *Stdio) int {
+	for _, clause := range cmd.Clauses {
+		status := clause.Cond.Execute(cfg, stdio)
+		if cfg.Unwinding() {
+			return status
+		}
+		if status == 0 {
+			return clause.Body.Execute(cfg, stdio)
+		}
+	}
+
+	if cmd.Else != nil {
+		return cmd.Else.Execute(cfg, stdio)
+	}
+	return 0
+}
+
+// Execute runs the commands of the group in the current shell.
+func (group *BraceGroup) Execute(cfg *Config, stdio *Stdio) int {
+	return group.Body.Execute(cfg, stdio)
+}
+
+// Execute defines the function, replacing any function of the same name.
+func (fn *FunctionDef) Execute(cfg *Config, stdio *Stdio) int {
+	cfg.Functions[fn.Name] = fn
+	return 0
+}
+
+// CallFunction runs the body of fn with args as the positional parameters
+// and returns its status, or the status given to `return`. Loops around
+// the call cannot be left with `break` or `continue` from inside it.
+func (cfg *Config) CallFunction(fn *FunctionDef, args []string, stdio *Stdio) int {
+	if len(cfg.FuncNames) >= MAX_FUNC_DEPTH {
+		fmt.Fprintf(stdio.Err, "%s: maximum function nesting level exceeded (%d)\n", fn.Name, MAX_FUNC_DEPTH)
+		return 1
+	}
+
+	positional, funcNames, loopDepth := cfg.Positional, cfg.FuncNames, cfg.LoopDepth
+	cfg.Positional = args
+	cfg.FuncNames = append([]string{fn.Name}, funcNames...)
+	cfg.LoopDepth = 0
+	cfg.Locals = append(cfg.Locals, make(map[string]*Variable))
+
+	defer func() {
+		cfg.popLocals()
+		cfg.Positional, cfg.FuncNames, cfg.LoopDepth = positional, funcNames, loopDepth
+	}()
+
+	status := NewPipeline(&PipelineNode{Commands: []CommandNode{fn.Body}}, cfg, stdio).Execute(cfg)
+	if cfg.Returning {
+		cfg.Returning = false
+		status = cfg.ReturnStatus
+	}
+	return status
+}
+
+// Execute runs the body of the first item with a pattern that matches the
+// word, then carries on according to the item's terminator. The status is
+// that of the last body run, or 0 if none are.
+func (cmd *CaseCommand) Execute(cfg *Config, stdio *Stdio) int {
+	word, err := cfg.ExpandWord(cfg.TildeExpand(cmd.Word, false), stdio)
+	if err != nil {
+		fmt.Fprintf(stdio.Err, "%s\n", err)
+		return 1
+	}
+
+	status, fallThrough := 0, false
+	for _, item := range cmd.Items {
+		if !fallThrough {
+			matched, err := cfg.matchCaseItem(item, word, stdio)
+			if err != nil {
+				fmt.Fprintf(stdio.Err, "%s\n", err)
+				return 1
+			}
+			if !matched {
+				continue
+			}
+		}
+
+		status = item.Body.Execute(cfg, stdio)
+		if cfg.Unwinding() {
+			return status
+		}
+
+		switch item.Terminator {
+		case ";&":
+			fallThrough = true
+		case ";;&":
+			fallThrough = false
+		default:
+			return status
+		}
+	}
+
+	return status
+}
+
+// matchCaseItem reports whether any of the patterns of item match word.
+// Patterns are expanded one at a time, stopping at the first match.
+func (cfg *Config) matchCaseItem(item *CaseItem, word string, stdio *Stdio) (bool, error) {
+	for _, pattern := range item.Patterns {
+		expanded, err := cfg.ExpandPattern(cfg.TildeExpand(pattern, false), stdio)
+		if err != nil {
+			return false, err
+		}
+		if CompilePattern(expanded, cfg.Options["extglob"]).Match(word) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Execute runs the body for as long as the condition succeeds, or fails
+// for an until loop. The status is that of the last time the body ran, or
+// 0 if it never did.
+func (loop *WhileCommand) Execute(cfg *Config, stdio *Stdio) int {
+	cfg.LoopDepth++
+	defer func() { cfg.LoopDepth-- }()
+
+	status := 0
+	for {
+		cond := loop.Cond.Execute(cfg, stdio)
+		if cfg.Unwinding() {
+			if cfg.endIteration() {
+				break
+			}
+			continue
+		}
+		if (cond == 0) == loop.Until {
+			break
+		}
+
+		status = loop.Body.Execute(cfg, stdio)
+		if cfg.endIteration() {
+			break
+		}
+	}
+
+	return status
+}
+
+// Execute runs the body once for each field the words expand to, or each
+// positional parameter if there are no words.
+func (loop *ForCommand) Execute(cfg *Config, stdio *Stdio) int {
+	values, body, done, err := cfg.expandLoopWords(loop.Words, stdio)
+	if err != nil {
+		fmt.Fprintf(stdio.Err, "%s\n", err)
+		return 1
+	}
+	defer done()
+
+	cfg.LoopDepth++
+	defer func() { cfg.LoopDepth-- }()
+
+	status := 0
+	for _, value := range values {
+		if err := cfg.SetVar(loop.Name, value); err != nil {
+			fmt.Fprintf(stdio.Err, "%s\n", err)
+			return 1
+		}
+
+		status = loop.Body.Execute(cfg, body)
+		if cfg.endIteration() {
+			break
+		}
+	}
+
+	return status
+}
+
+// Execute evaluates Init once, then runs the body and evaluates Step for
+// as long as Cond is non-zero.
+func (loop *ArithForCommand) Execute(cfg *Config, stdio *Stdio) int {
+	eval := func(expr *Word) (int64, bool) {
+		value, err := cfg.ExpandArith(expr, stdio)
+		if err != nil {
+			fmt.Fprintf(stdio.Err, "((: %s\n", err)
+			return 0, false
+		}
+		return value, true
+	}
+
+	if _, ok := eval(loop.Init); !ok {
+		return 1
+	}
+
+	cfg.LoopDepth++
+	defer func() { cfg.LoopDepth-- }()
+
+	status := 0
+	for {
+		if loop.Cond != nil {
+			cond, ok := eval(loop.Cond)
+			if !ok {
+				return 1
+			}
+			if cond == 0 {
+				break
+			}
+		}
+
+		status = loop.Body.Execute(cfg, stdio)
+		if cfg.endIteration() {
+			break
+		}
+
+		if _, ok := eval(loop.Step); !ok {
+			return 1
+		}
+	}
+
+	return status
+}
+
+// Execute prints a numbered menu of the values on stderr followed by the
+// PS3 prompt and reads a line from stdin into REPLY. The variable is set
+// to the value with that number, or to "" if there is none, and the body
+// is run. An empty line shows the menu again. The loop ends with `break`,
+// or with status 1 at the end of the input.
+func (loop *SelectCommand) Execute(cfg *Config, stdio *Stdio) int {
+	values, body, done, err := cfg.expandLoopWords(loop.Words, stdio)
+	if err != nil {
+		fmt.Fprintf(stdio.Err, "%s\n", err)
+		return 1
+	}
+	defer done()
+	if len(values) == 0 {
+		return 0
+	}
+
+	cfg.LoopDepth++
+	defer func() { cfg.LoopDepth-- }()
+
+	width := len(strconv.Itoa(len(values)))
+	showMenu := true
+
+	status := 0
+	for {
+		if showMenu {
+			for i, value := range values {
+				fmt.Fprintf(stdio.Err, "%*d) %s\n", width, i+1, value)
+			}
+		}
+
+		prompt, ok := cfg.GetVar("PS3")
+		if !ok {
+			prompt = DEFAULT_PS3
+		}
+		fmt.Fprint(stdio.Err, prompt)
+
+		line, complete := readInputLine(stdio.In, cfg, false)
+		if !complete {
+			fmt.Fprintln(stdio.Err)
+			return 1
+		}
+
+		if err := cfg.SetVar("REPLY", line); err != nil {
+			fmt.Fprintf(stdio.Err, "%s\n", err)
+			return 1
+		}
+
+		showMenu = strings.TrimSpace(line) == ""
+		if showMenu {
+			continue
+		}
+
+		value := ""
+		if n, err := strconv.Atoi(strings.TrimSpace(line)); err == nil && n >= 1 && n <= len(values) {
+			value = values[n-1]
+		}
+		if err := cfg.SetVar(loop.Name, value); err != nil {
+			fmt.Fprintf(stdio.Err, "%s\n", err)
+			return 1
+		}
+
+		status = loop.Body.Execute(cfg, body)
+		if cfg.endIteration() {
+			break
+		}
+	}
+
+	return status
+}
+
+// expandLoopWords returns the fields words expand to, or the positional
+// parameters if words is nil. Process substitutions in the words last for
+// the whole loop, so the body is run with the returned stdio, which has
+// their pipes under the same file descriptors as the shell, and done
+// closes them once the loop is over.
+func (cfg *Config) expandLoopWords(words []*Word, stdio *Stdio) (values []string, body *Stdio, done func(), err error) {
+	if words == nil {
+		return slices.Clone(cfg.Positional), stdio, func() {}, nil
+	}
+
+	cfg.ProcSubs = nil
+	values, err = cfg.ExpandWords(words, stdio)
+	procSubs := cfg.ProcSubs
+	cfg.ProcSubs = nil
+
+	done = func() {
+		for _, sub := range procSubs {
+			sub.File.Close()
+			<-sub.Done
+		}
+	}
+	if err != nil {
+		done()
+		return nil, nil, nil, err
+	}
+
+	body = stdio
+	if len(procSubs) > 0 {
+		body = &Stdio{In: stdio.In, Out: stdio.Out, Err: stdio.Err, Extra: slices.Clone(stdio.Extra)}
+		for _, sub := range procSubs {
+			fd := int(sub.File.Fd())
+			for len(body.Extra) <= fd-3 {
+				body.Extra = append(body.Extra, nil)
+			}
+			body.Extra[fd-3] = sub.File
+		}
+	}
+
+	return values, body, done, nil
+}
+
+// Unwinding reports whether the remaining commands of a list are skipped
+// because of `exit`, `return`, `break` or `continue`.
+func (cfg *Config) Unwinding() bool {
+	return cfg.Exiting || cfg.Returning || cfg.Breaking > 0 || cfg.Continuing > 0
+}
+
+// endIteration is called at the end of each iteration of a loop and
+// reports whether the loop should stop. A `break N` or `continue N` stops
+// the N-1 innermost loops, the last of which then breaks or continues.
+func (cfg *Config) endIteration() bool {
+	switch {
+	case cfg.Exiting, cfg.Returning:
+		return true
+	case cfg.Breaking > 0:
+		cfg.Breaking--
+		return true
+	case cfg.Continuing > 0:
+		cfg.Continuing--
+		return cfg.Continuing > 0
+	}
+	return false
+}
+
+// Subshell returns a copy of the shell state for commands that must not
+// change the variables or options of the parent shell, such as those run
+// by a command substitution.
+func (cfg *Config) Subshell() *Config {
+	subshell := *cfg
+	subshell.IsSubshell = true
+	subshell.Options = maps.Clone(cfg.Options)
+	subshell.Functions = maps.Clone(cfg.Functions)
+	subshell.Variables = make(map[string]*Variable, len(cfg.Variables))
+	subshell.Locals = make([]map[string]*Variable, 0, len(cfg.Locals))
+
+	// Every variable is copied once, so that the variables hidden by
+	// locals are brought back as the subshell's own when a function
+	// returns in it
+	copies := make(map[*Variable]*Variable, len(cfg.Variables))
+	copyVariable := func(variable *Variable) *Variable {
+		if variable == nil {
+			return nil
+		}
+		if copied, ok := copies[variable]; ok {
+			return copied
+		}
+		copied := *variable
+		copies[variable] = &copied
+		return &copied
+	}
+
+	for name, variable := range cfg.Variables {
+		subshell.Variables[name] = copyVariable(variable)
+	}
+
+	for _, scope := range cfg.Locals {
+		copied := make(map[string]*Variable, len(scope))
+		for name, prev := range scope {
+			copied[name] = copyVariable(prev)
+		}
+		subshell.Locals = append(subshell.Locals, copied)
+	}
+
+	return &subshell
+}
+
+// WorkingDirectory returns the directory relative paths are resolved
+// against. A subshell keeps its own working directory instead of changing
+// the one of the process, which is shared with the shells running
+// alongside it.
+func (cfg *Config) WorkingDirectory() (string, error) {
+	if cfg.CurrentDirectory != "" {
+		return cfg.CurrentDirectory, nil
+	}
+	return os.Getwd()
+}
+
+// ResolvePath returns path relative to the working directory of the shell
+// so that it can be used by the process.
+func (cfg *Config) ResolvePath(path string) string {
+	if path == "" || filepath.IsAbs(path) || cfg.CurrentDirectory == "" {
+		return path
+	}
+	return cfg.CurrentDirectory + "/" + path
+}
+
+// RunSubshell runs list in a subshell and returns its status.
+func (cfg *Config) RunSubshell(list *List, stdio *Stdio) int {
+	subshell := cfg.Subshell()
+	status := list.Execute(subshell, stdio)
+
+	if subshell.Exiting {
+		return subshell.ExitStatus
+	}
+	return status
+}
@@ -0,0 +1,964 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestConfig() *Config {
+	return &Config{
+		Options:   make(map[string]bool),
+		Variables: VariablesFromEnviron(),
+		Functions: make(map[string]*FunctionDef),
+	}
+}
+
+func runLine(t *testing.T, cfg *Config, input string) int {
+	t.Helper()
+
+	list, err := Parse(input)
+	if err != nil {
+		t.Fatalf("unexpected error parsing %#v: %s", input, err)
+	}
+	return list.Execute(cfg, NewStdio())
+}
+
+func TestExitStatus(t *testing.T) {
+	dir := t.TempDir()
+
+	notExecutable := filepath.Join(dir, "script.sh")
+	os.WriteFile(notExecutable, []byte("echo hi\n"), 0o644)
+
+	testCases := []struct {
+		name     string
+		input    string
+		expected int
+	}{
+		{name: "success", input: "true", expected: 0},
+		{name: "failure", input: "false", expected: 1},
+		{name: "exit code", input: "sh -c 'exit 3'", expected: 3},
+		{name: "command not found", input: "doesnotexist 2> /dev/null", expected: 127},
+		{name: "file not found", input: "./doesnotexist 2> /dev/null", expected: 127},
+		{name: "not executable", input: notExecutable + " 2> /dev/null", expected: 126},
+		{name: "killed by signal", input: "sh -c 'kill -TERM $$'", expected: 128 + 15},
+		{name: "last command in pipeline", input: "false | true", expected: 0},
+		{name: "builtin failure", input: "cd /doesnotexist 2> /dev/null", expected: 1},
+		{name: "builtin success", input: "pwd > /dev/null", expected: 0},
+		{name: "and list", input: "true && false", expected: 1},
+		{name: "or list", input: "false || true", expected: 0},
+		{name: "redirection failure", input: "echo hi < /doesnotexist 2> /dev/null", expected: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if status := runLine(t, newTestConfig(), tc.input); status != tc.expected {
+				t.Fatalf("expected status %d, got %d", tc.expected, status)
+			}
+		})
+	}
+}
+
+func TestLastStatusParam(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.txt")
+
+	cfg := newTestConfig()
+	runLine(t, cfg, "sh -c 'exit 7'; echo $? \"($?)\" '$?' > "+out)
+
+	content, _ := os.ReadFile(out)
+	if got := strings.TrimSpace(string(content)); got != "7 (7) $?" {
+		t.Fatalf("expected: %#v, got: %#v", "7 (7) $?", got)
+	}
+
+	if cfg.LastStatus != 0 {
+		t.Fatalf("expected $? to be 0 after echo, got %d", cfg.LastStatus)
+	}
+}
+
+func runLineOutput(t *testing.T, cfg *Config, input string) string {
+	t.Helper()
+
+	out := filepath.Join(t.TempDir(), "out.txt")
+	runLine(t, cfg, input+" > "+out)
+
+	content, _ := os.ReadFile(out)
+	return strings.TrimSuffix(string(content), "\n")
+}
+
+func TestPipeStatus(t *testing.T) {
+	testCases := []struct {
+		name       string
+		options    []string
+		input      string
+		status     int
+		pipeStatus string
+	}{
+		{
+			name:       "status of last command",
+			input:      "sh -c 'exit 2' | sh -c 'exit 3' | true",
+			status:     0,
+			pipeStatus: "2 3 0",
+		},
+		{
+			name:       "pipefail uses last failure",
+			options:    []string{"pipefail"},
+			input:      "sh -c 'exit 2' | sh -c 'exit 3' | true",
+			status:     3,
+			pipeStatus: "2 3 0",
+		},
+		{
+			name:       "pipefail with all commands succeeding",
+			options:    []string{"pipefail"},
+			input:      "true | true",
+			status:     0,
+			pipeStatus: "0 0",
+		},
+		{
+			name:       "single command",
+			input:      "false",
+			status:     1,
+			pipeStatus: "1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			for _, option := range tc.options {
+				runLine(t, cfg, "set -o "+option)
+			}
+
+			if status := runLine(t, cfg, tc.input); status != tc.status {
+				t.Fatalf("expected status %d, got %d", tc.status, status)
+			}
+
+			if got := runLineOutput(t, cfg, "echo ${PIPESTATUS[@]}"); got != tc.pipeStatus {
+				t.Fatalf("expected PIPESTATUS: %#v, got: %#v", tc.pipeStatus, got)
+			}
+		})
+	}
+}
+
+func TestPipelineSubshells(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "assignment does not leak", input: `z=1 | true; echo "[$z]"`, expected: "[]"},
+		{name: "pipe standard error", input: "sh -c 'echo out; echo err >&2' |& sort", expected: "err\nout"},
+		{name: "pipe standard error of compound command", input: "{ echo err >&2; } |& tr a-z A-Z", expected: "ERR"},
+		{name: "cd does not leak", input: "cd /tmp; cd / | true; pwd", expected: "/tmp"},
+		{name: "exit only ends the command", input: "echo hi | exit 3; echo $? ${PIPESTATUS[@]}", expected: "3 0 3"},
+		{name: "break stays in the command", input: "for i in 1 2; do break | true; echo $i; done", expected: "1\n2"},
+		{name: "return stays in the command", input: "f() { return 2 | true; echo after; }; f", expected: "after"},
+		{name: "loop into while read", input: "for ((i = 0; i < 1000; i++)); do echo $i; done | { while read l; do ((s += l)); done; echo $s; }", expected: "499500"},
+		{name: "functions with locals", input: "h() { local a=$1 b c; }; f() { for ((k = 0; k < 2000; k++)); do h $k; done; echo $k; }; f | f", expected: "2000"},
+	}
+
+	t.Chdir(t.TempDir())
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := runLineOutput(t, newTestConfig(), "{ "+tc.input+"; }"); got != tc.expected {
+				t.Fatalf("expected: %#v, got: %#v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestSetOptions(t *testing.T) {
+	cfg := newTestConfig()
+
+	runLine(t, cfg, "set -o pipefail")
+	if !cfg.Options["pipefail"] {
+		t.Fatalf("expected pipefail to be on")
+	}
+
+	runLine(t, cfg, "set +o pipefail")
+	if cfg.Options["pipefail"] {
+		t.Fatalf("expected pipefail to be off")
+	}
+
+	if status := runLine(t, cfg, "set -o doesnotexist 2> /dev/null"); status != 1 {
+		t.Fatalf("expected status 1 for invalid option, got %d", status)
+	}
+
+	runLine(t, cfg, "set -C")
+	if !cfg.Options["noclobber"] {
+		t.Fatalf("expected noclobber to be on")
+	}
+
+	runLine(t, cfg, "set +C")
+	if cfg.Options["noclobber"] {
+		t.Fatalf("expected noclobber to be off")
+	}
+
+	if status := runLine(t, cfg, "set -Z 2> /dev/null"); status != 2 {
+		t.Fatalf("expected status 2 for invalid flag, got %d", status)
+	}
+}
+
+func TestNoclobber(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		status   int
+		expected string
+	}{
+		{name: "overwrite without noclobber", input: "echo new > out.txt", status: 0, expected: "new\n"},
+		{name: "refuse to overwrite", input: "set -o noclobber; echo new > out.txt", status: 1, expected: "old\n"},
+		{name: "refuse to overwrite both", input: "set -C; echo new &> out.txt", status: 1, expected: "old\n"},
+		{name: "force overwrite", input: "set -C; echo new >| out.txt", status: 0, expected: "new\n"},
+		{name: "append", input: "set -C; echo new >> out.txt", status: 0, expected: "old\nnew\n"},
+		{name: "new file", input: "set -C; echo new > new.txt; set +C; cat new.txt > out.txt", status: 0, expected: "new\n"},
+		{name: "not a regular file", input: "set -C; echo new > /dev/null", status: 0, expected: "old\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			os.WriteFile("out.txt", []byte("old\n"), 0o644)
+
+			if status := runLine(t, newTestConfig(), tc.input+" 2> /dev/null"); status != tc.status {
+				t.Fatalf("expected status %d, got %d", tc.status, status)
+			}
+
+			content, _ := os.ReadFile("out.txt")
+			if string(content) != tc.expected {
+				t.Fatalf("expected: %#v, got: %#v", tc.expected, string(content))
+			}
+		})
+	}
+}
+
+func TestVariables(t *testing.T) {
+	testCases := []struct {
+		name     string
+		setup    []string
+		input    string
+		expected string
+	}{
+		{
+			name:     "assignment",
+			setup:    []string{"FOO=bar"},
+			input:    "echo $FOO ${FOO}",
+			expected: "bar bar",
+		},
+		{
+			name:     "assignments apply in order",
+			setup:    []string{"A=1 B=$A"},
+			input:    "echo $B",
+			expected: "1",
+		},
+		{
+			name:     "quoted value",
+			setup:    []string{`MSG="hello   world"`},
+			input:    `echo "$MSG"`,
+			expected: "hello   world",
+		},
+		{
+			name:     "unexported variables are not in the environment",
+			setup:    []string{"FOO=bar"},
+			input:    `sh -c 'echo "[$FOO]"'`,
+			expected: "[]",
+		},
+		{
+			name:     "exported variables are in the environment",
+			setup:    []string{"FOO=bar", "export FOO"},
+			input:    `sh -c 'echo "[$FOO]"'`,
+			expected: "[bar]",
+		},
+		{
+			name:     "export with value",
+			setup:    []string{"export FOO=baz"},
+			input:    `sh -c 'echo "[$FOO]"'`,
+			expected: "[baz]",
+		},
+		{
+			name:     "export -n",
+			setup:    []string{"export FOO=baz", "export -n FOO"},
+			input:    `sh -c 'echo "[$FOO]"'`,
+			expected: "[]",
+		},
+		{
+			name:     "export -n keeps the value",
+			setup:    []string{"export FOO=baz", "export -n FOO"},
+			input:    "echo $FOO",
+			expected: "baz",
+		},
+		{
+			name:     "unset",
+			setup:    []string{"FOO=bar", "unset FOO"},
+			input:    `echo "[$FOO]"`,
+			expected: "[]",
+		},
+		{
+			name:     "readonly",
+			setup:    []string{"readonly FOO=bar", "FOO=baz 2> /dev/null", "unset FOO 2> /dev/null"},
+			input:    "echo $FOO",
+			expected: "bar",
+		},
+		{
+			name:     "export listing",
+			setup:    []string{"export FOO='a \"b\"'"},
+			input:    "export -p | grep FOO",
+			expected: `declare -x FOO="a \"b\""`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			for _, line := range tc.setup {
+				runLine(t, cfg, line)
+			}
+
+			if got := runLineOutput(t, cfg, tc.input); got != tc.expected {
+				t.Fatalf("expected: %#v, got: %#v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestVariableErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+	}{
+		{name: "assign readonly", input: "readonly FOO=bar; FOO=baz"},
+		{name: "unset readonly", input: "readonly FOO=bar; unset FOO"},
+		{name: "export invalid name", input: "export 1FOO=bar"},
+		{name: "unset invalid name", input: "unset FOO-BAR"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if status := runLine(t, newTestConfig(), tc.input+" 2> /dev/null"); status != 1 {
+				t.Fatalf("expected status 1, got %d", status)
+			}
+		})
+	}
+}
+
+func TestCommandEnvironment(t *testing.T) {
+	testCases := []struct {
+		name     string
+		setup    []string
+		input    string
+		expected string
+	}{
+		{
+			name:     "assignment is passed to the command",
+			input:    `FOO=bar sh -c 'echo "[$FOO]"'`,
+			expected: "[bar]",
+		},
+		{
+			name:     "assignment does not change shell variable",
+			setup:    []string{"FOO=old", "FOO=new true"},
+			input:    "echo $FOO",
+			expected: "old",
+		},
+		{
+			name:     "assignment does not create shell variable",
+			setup:    []string{"FOO=new true"},
+			input:    `echo "[$FOO]"`,
+			expected: "[]",
+		},
+		{
+			name:     "multiple assignments",
+			input:    `A=1 B=2 sh -c 'echo $A$B'`,
+			expected: "12",
+		},
+		{
+			name:     "later assignment sees earlier one",
+			input:    `A=1 B=$A sh -c 'echo $B'`,
+			expected: "1",
+		},
+		{
+			name:     "arguments are expanded before assignments",
+			setup:    []string{"A=old"},
+			input:    `A=new sh -c 'echo $0 $A' $A`,
+			expected: "old new",
+		},
+		{
+			name:     "unexported variable is exported for the command",
+			setup:    []string{"FOO=bar"},
+			input:    `FOO=$FOO sh -c 'echo "[$FOO]"'`,
+			expected: "[bar]",
+		},
+		{
+			name:     "assignment in pipeline",
+			input:    `LANG=C echo hi | FOO=bar sh -c 'cat; echo $FOO'`,
+			expected: "hi\nbar",
+		},
+		{
+			name:     "PATH is used to find the command",
+			input:    `PATH=/doesnotexist sh -c true 2> /dev/null; echo $?`,
+			expected: "127",
+		},
+		{
+			name:     "unexported PATH is used to find the command",
+			setup:    []string{"export -n PATH"},
+			input:    `sh -c 'echo found'`,
+			expected: "found",
+		},
+		{
+			name:     "unexported PATH assignment is used to find the command",
+			setup:    []string{"export -n PATH", "PATH=/doesnotexist"},
+			input:    `sh -c true 2> /dev/null; echo $?`,
+			expected: "127",
+		},
+		{
+			name:     "PATH assignment overrides PATH variable",
+			setup:    []string{"PATH=/doesnotexist"},
+			input:    `PATH=/bin:/usr/bin sh -c 'echo found'`,
+			expected: "found",
+		},
+		{
+			name:     "assignment applies to builtin",
+			setup:    []string{"FOO=old"},
+			input:    "FOO=new export -p | grep FOO",
+			expected: `declare -x FOO="new"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			for _, line := range tc.setup {
+				runLine(t, cfg, line)
+			}
+
+			if got := runLineOutput(t, cfg, tc.input); got != tc.expected {
+				t.Fatalf("expected: %#v, got: %#v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "reply", input: `printf '  a  b  \n' | { read; echo "[$REPLY]"; }`, expected: "[  a  b  ]"},
+		{name: "one name gets the line", input: `printf '  a  b  \n' | { read x; echo "[$x]"; }`, expected: "[a  b]"},
+		{name: "split into names", input: `printf 'a b c d\n' | { read x y z; echo "[$x][$y][$z]"; }`, expected: "[a][b][c d]"},
+		{name: "more names than fields", input: `printf 'a\n' | { read x y; echo "[$x][$y]"; }`, expected: "[a][]"},
+		{name: "custom IFS", input: `printf 'a:b::c\n' | { IFS=: read x y z; echo "[$x][$y][$z]"; }`, expected: "[a][b][:c]"},
+		{name: "only first line", input: `printf 'a\nb\n' | { read x; echo $x; }`, expected: "a"},
+		{name: "backslash escapes", input: `printf 'a\\\\b\\\nc\n' | { read x; echo $x; }`, expected: `a\bc`},
+		{name: "raw", input: `printf 'a\\\\b\n' | { read -r x; echo $x; }`, expected: `a\\b`},
+		{name: "end of input", input: `printf 'partial' | { read x; echo $? $x; }`, expected: "1 partial"},
+		{name: "invalid name", input: "read 1x < /dev/null 2> /dev/null; echo $?", expected: "1"},
+		{name: "pipeline runs in subshell", input: `printf 'a\n' | read x; echo "[$x]"`, expected: "[]"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := runLineOutput(t, newTestConfig(), tc.input); got != tc.expected {
+				t.Fatalf("expected: %#v, got: %#v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestReadStdin(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "rest left for command", input: `read a; echo "[$a]"; cat`, expected: "[1]\n2\n3"},
+		{name: "successive reads", input: `read a; read b; echo "[$a][$b]"; cat`, expected: "[1][2]\n3"},
+		{name: "select", input: `select x in a b; do echo "[$x]"; break; done 2> /dev/null; cat`, expected: "[a]\n2\n3"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			in := filepath.Join(t.TempDir(), "in.txt")
+			if err := os.WriteFile(in, []byte("1\n2\n3\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			file, err := os.Open(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			stdin := os.Stdin
+			os.Stdin = file
+			defer func() { os.Stdin = stdin }()
+
+			cfg := newTestConfig()
+			cfg.StdinReader = bufio.NewReader(file)
+
+			if got := runLineOutput(t, cfg, "{ "+tc.input+"; }"); got != tc.expected {
+				t.Fatalf("expected: %#v, got: %#v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestFileDescriptors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		file     string
+		expected string
+	}{
+		{
+			name:     "stderr to stdout",
+			input:    "sh -c 'echo out; echo err >&2' > out.txt 2>&1",
+			file:     "out.txt",
+			expected: "out\nerr\n",
+		},
+		{
+			name:     "applied left to right",
+			input:    "sh -c 'echo out; echo err >&2' 2>&1 > out.txt | cat > pipe.txt",
+			file:     "pipe.txt",
+			expected: "err\n",
+		},
+		{
+			name:     "stdout to stderr",
+			input:    "echo hi 2> out.txt >&2",
+			file:     "out.txt",
+			expected: "hi\n",
+		},
+		{
+			name:     "extra file descriptor",
+			input:    "sh -c 'echo three >&3' 3> out.txt",
+			file:     "out.txt",
+			expected: "three\n",
+		},
+		{
+			name:     "duplicate input",
+			input:    "cat 4< in.txt <&4 > out.txt",
+			file:     "out.txt",
+			expected: "input\n",
+		},
+		{
+			name:     "read and write",
+			input:    "cat <> in.txt > out.txt",
+			file:     "out.txt",
+			expected: "input\n",
+		},
+		{
+			name:     "read and write creates file",
+			input:    "true <> new.txt",
+			file:     "new.txt",
+			expected: "",
+		},
+		{
+			name:     "close file descriptor",
+			input:    "sh -c 'echo x >&3 || echo closed' 3> other.txt 3>&- 2> /dev/null > out.txt",
+			file:     "out.txt",
+			expected: "closed\n",
+		},
+		{
+			name:     "close stdin",
+			input:    `sh -c 'test -e /proc/$$/fd/0 && echo open || echo closed' <&- > out.txt`,
+			file:     "out.txt",
+			expected: "closed\n",
+		},
+		{
+			name:     "close stdout",
+			input:    `sh -c 'test -e /proc/$$/fd/1 && echo open >&2 || echo closed >&2' >&- 2> out.txt`,
+			file:     "out.txt",
+			expected: "closed\n",
+		},
+		{
+			name:     "close stderr",
+			input:    `sh -c 'test -e /proc/$$/fd/2 && echo open || echo closed' 2>&- > out.txt`,
+			file:     "out.txt",
+			expected: "closed\n",
+		},
+		{
+			name:     "bad file descriptor",
+			input:    "echo hi >&7; echo $? > out.txt",
+			file:     "out.txt",
+			expected: "1\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			os.WriteFile("in.txt", []byte("input\n"), 0o644)
+
+			runLine(t, newTestConfig(), tc.input)
+
+			content, err := os.ReadFile(tc.file)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if string(content) != tc.expected {
+				t.Fatalf("expected: %#v, got: %#v", tc.expected, string(content))
+			}
+		})
+	}
+}
+
+func TestHereDocuments(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "expanded body",
+			input:    "x=world\ncat <<EOF > out.txt\nhello $x \"$(echo sub)\" $((1 + 2))\n\\$x \\\\ \\q\nEOF",
+			expected: "hello world \"sub\" 3\n$x \\ \\q\n",
+		},
+		{
+			name:     "quoted delimiter",
+			input:    "x=world\ncat <<'EOF' > out.txt\nhello $x $(echo sub) \\$x\nEOF",
+			expected: "hello $x $(echo sub) \\$x\n",
+		},
+		{
+			name:     "strip tabs",
+			input:    "cat <<-END > out.txt\n\t\tindented\n\tEND",
+			expected: "indented\n",
+		},
+		{
+			name:     "delimiter must match whole line",
+			input:    "cat <<EOF > out.txt\n EOF\nEOF \nEOF",
+			expected: " EOF\nEOF \n",
+		},
+		{
+			name:     "backslash newline",
+			input:    "cat <<EOF > out.txt\none \\\ntwo\nEOF",
+			expected: "one two\n",
+		},
+		{
+			name:     "multiple here-documents",
+			input:    "cat <<A > out.txt; cat <<B >> out.txt\na\nA\nb\nB\necho after >> out.txt",
+			expected: "a\nb\nafter\n",
+		},
+		{
+			name:     "empty body",
+			input:    "cat <<EOF > out.txt\nEOF",
+			expected: "",
+		},
+		{
+			name:     "here-string",
+			input:    "x='a  b'; cat <<< $x > out.txt",
+			expected: "a  b\n",
+		},
+		{
+			name:     "here-string in pipeline",
+			input:    "tr a-z A-Z <<< \"hello\" | cat > out.txt",
+			expected: "HELLO\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+
+			runLine(t, newTestConfig(), tc.input)
+
+			content, err := os.ReadFile("out.txt")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if string(content) != tc.expected {
+				t.Fatalf("expected: %#v, got: %#v", tc.expected, string(content))
+			}
+		})
+	}
+}
+
+func TestIfCommand(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "true condition", input: "if true; then x=yes; fi; echo $x", expected: "yes"},
+		{name: "false condition", input: "if false; then x=yes; else x=no; fi; echo $x", expected: "no"},
+		{name: "elif", input: "x=2; if [[ $x == 1 ]]; then x=one; elif [[ $x == 2 ]]; then x=two; else x=many; fi; echo $x", expected: "two"},
+		{name: "no branch taken", input: "if false; then echo yes; fi; echo $?", expected: "0"},
+		{name: "status of body", input: "if true; then false; fi; echo $?", expected: "1"},
+		{name: "last command of condition", input: "if false; true; then x=yes; fi; echo $x", expected: "yes"},
+		{name: "and-or condition", input: "if true && false || true; then x=yes; fi; echo $x", expected: "yes"},
+		{name: "multiple lines", input: "if false\nthen\n  x=yes\nelse\n  x=no\nfi\necho $x", expected: "no"},
+		{name: "nested", input: "if true; then if false; then x=a; else x=b; fi; fi; echo $x", expected: "b"},
+		{name: "in pipeline", input: "if true; then echo yes; fi | tr a-z A-Z", expected: "YES"},
+		{name: "in and list", input: "true && if true; then x=yes; fi; echo $x", expected: "yes"},
+		{name: "reserved words as arguments", input: "echo if then fi", expected: "if then fi"},
+		{name: "quoted reserved word", input: "'if' 2> /dev/null; echo $?", expected: "127"},
+		{name: "comments", input: "if true; then # comment\n  x=yes # another\nfi\necho $x", expected: "yes"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := runLineOutput(t, newTestConfig(), tc.input); got != tc.expected {
+				t.Fatalf("expected: %#v, got: %#v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestLoops(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "while", input: "i=0; while [[ $i -lt 3 ]]; do echo $i; ((i++)); done", expected: "0\n1\n2"},
+		{name: "until", input: "i=0; until (( i >= 3 )); do echo $i; ((i++)); done", expected: "0\n1\n2"},
+		{name: "while never runs", input: "while false; do echo a; done; echo $?", expected: "0"},
+		{name: "status of last iteration", input: "for x in a b; do [[ $x == a ]]; done; echo $?", expected: "1"},
+		{name: "for in words", input: "for x in a 'b c' d; do echo $x; done", expected: "a\nb c\nd"},
+		{name: "for expands words", input: "v='a b'; for x in $v {1..2}; do echo $x; done", expected: "a\nb\n1\n2"},
+		{name: "for with no words", input: "for x in; do echo $x; done; echo $?", expected: "0"},
+		{name: "for positional parameters", input: "set -- a b; for x; do echo $x; done", expected: "a\nb"},
+		{name: "for keeps last value", input: "for x in a b; do true; done; echo $x", expected: "b"},
+		{name: "multiple lines", input: "for x in a b\ndo\n  echo $x\ndone", expected: "a\nb"},
+		{name: "break", input: "for x in a b c; do [[ $x == b ]] && break; echo $x; done", expected: "a"},
+		{name: "continue", input: "for x in a b c; do [[ $x == b ]] && continue; echo $x; done", expected: "a\nc"},
+		{name: "break in condition", input: "while break; do echo a; done; echo b", expected: "b"},
+		{name: "break N", input: "for x in a b; do for y in 1 2; do echo $x$y; break 2; done; done", expected: "a1"},
+		{name: "continue N", input: "for x in a b; do for y in 1 2; do echo $x$y; continue 2; done; echo no; done", expected: "a1\nb1"},
+		{name: "break N larger than depth", input: "for x in a b; do break 5; done; echo $x", expected: "a"},
+		{name: "break skips rest of list", input: "for x in a; do if true; then break; fi; echo no; done; echo yes", expected: "yes"},
+		{name: "break outside loop", input: "break 2> /dev/null; echo $?", expected: "0"},
+		{name: "break with invalid count", input: "for x in a b; do break 0 2> /dev/null; echo $?; done", expected: "1\n1"},
+		{name: "in pipeline", input: "for x in c a b; do echo $x; done | sort", expected: "a\nb\nc"},
+		{name: "process substitution", input: "for f in <(echo x) <(echo y); do cat $f; done", expected: "x\ny"},
+		{name: "process substitution read by builtin", input: "for f in <(echo z); do read v < $f; echo $v; done", expected: "z"},
+		{name: "select process substitution", input: "select f in <(echo chosen); do cat $f; break; done <<< 1 2> /dev/null", expected: "chosen"},
+		{name: "arithmetic for", input: "for ((i=0; i<3; i++)); do echo $i; done", expected: "0\n1\n2"},
+		{name: "arithmetic for with variables", input: "n=3; for (( i = n; i > 0; i -= $n - 2 )); do echo $i; done", expected: "3\n2\n1"},
+		{name: "arithmetic for without condition", input: "for ((i=0; ; i++)); do (( i == 2 )) && break; echo $i; done", expected: "0\n1"},
+		{name: "arithmetic for continue runs step", input: "for ((i=0; i<4; i++)); do (( i % 2 )) && continue; echo $i; done", expected: "0\n2"},
+		{name: "arithmetic for multiple lines", input: "for ((i=0; i<2; i++))\ndo\n  echo $i\ndone", expected: "0\n1"},
+		{name: "arithmetic for invalid expression", input: "for ((i=0; i<; i++)); do echo $i; done 2> /dev/null; echo $?", expected: "1"},
+		{name: "reserved words as arguments", input: "echo for in do done", expected: "for in do done"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := runLineOutput(t, newTestConfig(), tc.input); got != tc.expected {
+				t.Fatalf("expected: %#v, got: %#v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestCaseCommand(t *testing.T) {
+	testCases := []struct {
+		name     string
+		options  []string
+		input    string
+		expected string
+	}{
+		{name: "first match", input: "case b in a) echo A;; b) echo B;; *) echo other;; esac", expected: "B"},
+		{name: "alternatives", input: "case begin in start|begin) echo go;; esac", expected: "go"},
+		{name: "glob patterns", input: "case x.tar.gz in *.zip) echo zip;; *.tar.gz) echo tgz;; esac", expected: "tgz"},
+		{name: "default", input: "case z in a) echo A;; *) echo other;; esac", expected: "other"},
+		{name: "no match", input: "false; case z in a) echo A;; esac; echo $?", expected: "0"},
+		{name: "empty body", input: "case a in a) ;; esac; echo $?", expected: "0"},
+		{name: "status of body", input: "case a in a) false;; esac; echo $?", expected: "1"},
+		{name: "fall through", input: "case a in a) echo 1;& b) echo 2;; c) echo 3;; esac", expected: "1\n2"},
+		{name: "continue testing", input: "case ab in a*) echo 1;;& c*) echo 2;;& *b) echo 3;; *) echo 4;; esac", expected: "1\n3"},
+		{name: "expanded word", input: "x=b; case $x in a) echo A;; b) echo B;; esac", expected: "B"},
+		{name: "quoted pattern", input: "case abc in 'a*') echo quoted;; a*) echo glob;; esac", expected: "glob"},
+		{name: "expanded pattern", input: "p='a*'; case abc in $p) echo match;; esac", expected: "match"},
+		{name: "parenthesized pattern", input: "case a in (a) echo A;; esac", expected: "A"},
+		{name: "last terminator optional", input: "case a in a) echo A\nesac", expected: "A"},
+		{name: "multiple lines", input: "case b in\n  a)\n    echo A\n    ;;\n  b)\n    echo B\n    ;;\nesac", expected: "B"},
+		{name: "extglob", options: []string{"extglob"}, input: "case baz in !(foo|bar)) echo other;; esac", expected: "other"},
+		{name: "in loop", input: "for x in a b c; do case $x in b) continue;; esac; echo $x; done", expected: "a\nc"},
+		{name: "reserved words as arguments", input: "echo case in esac", expected: "case in esac"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			for _, option := range tc.options {
+				cfg.Options[option] = true
+			}
+
+			if got := runLineOutput(t, cfg, tc.input); got != tc.expected {
+				t.Fatalf("expected: %#v, got: %#v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+		menu     string
+	}{
+		{
+			name:     "choice",
+			input:    "select x in a b c; do echo \"$REPLY $x\"; break; done <<< 2",
+			expected: "2 b\n",
+			menu:     "1) a\n2) b\n3) c\n#? ",
+		},
+		{
+			name:     "invalid choice",
+			input:    "select x in a b; do echo \"$REPLY [$x]\"; break; done <<< 5",
+			expected: "5 []\n",
+			menu:     "1) a\n2) b\n#? ",
+		},
+		{
+			name:     "empty line shows menu again",
+			input:    "printf '\\n1\\n' | select x in a; do echo $x; break; done",
+			expected: "a\n",
+			menu:     "1) a\n#? 1) a\n#? ",
+		},
+		{
+			name:     "end of input",
+			input:    "select x in a; do echo $x; done < /dev/null; echo $?",
+			expected: "1\n",
+			menu:     "1) a\n#? \n",
+		},
+		{
+			name:     "prompt and positional parameters",
+			input:    "set -- a b; PS3='pick: '; select x; do echo $x; break; done <<< 1",
+			expected: "a\n",
+			menu:     "1) a\n2) b\npick: ",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			list, err := Parse(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected error parsing %#v: %s", tc.input, err)
+			}
+
+			out, _ := os.Create("out.txt")
+			menu, _ := os.Create("menu.txt")
+			list.Execute(newTestConfig(), &Stdio{In: os.Stdin, Out: out, Err: menu})
+			out.Close()
+			menu.Close()
+
+			if content, _ := os.ReadFile("out.txt"); string(content) != tc.expected {
+				t.Fatalf("expected output: %#v, got: %#v", tc.expected, string(content))
+			}
+			if content, _ := os.ReadFile("menu.txt"); string(content) != tc.menu {
+				t.Fatalf("expected menu: %#v, got: %#v", tc.menu, string(content))
+			}
+		})
+	}
+}
+
+func TestLoopRedirections(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.WriteFile("in.txt", []byte("one\ntwo\n"), 0o644)
+
+	testCases := []struct {
+		name     string
+		input    string
+		file     string
+		expected string
+	}{
+		{name: "input", input: "while read line; do echo \"<$line>\"; done < in.txt > out.txt", file: "out.txt", expected: "<one>\n<two>\n"},
+		{name: "append", input: "for x in a b; do echo $x; done >> in.txt", file: "in.txt", expected: "one\ntwo\na\nb\n"},
+		{name: "stderr", input: "for x in a b; do echo $x >&2; done 2> err.txt", file: "err.txt", expected: "a\nb\n"},
+		{name: "file descriptor", input: "for x in a b; do echo $x >&3; done 3> fd.txt", file: "fd.txt", expected: "a\nb\n"},
+		{name: "here-string", input: "while read a b; do echo $b $a; done <<< 'x y' > str.txt", file: "str.txt", expected: "y x\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runLine(t, newTestConfig(), tc.input)
+
+			content, _ := os.ReadFile(tc.file)
+			if got := string(content); got != tc.expected {
+				t.Fatalf("expected: %#v, got: %#v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "define and call", input: "greet() { echo hello; }; greet", expected: "hello"},
+		{name: "function keyword", input: "function greet { echo hello; }; greet", expected: "hello"},
+		{name: "function keyword with parentheses", input: "function greet() { echo hello; }; greet", expected: "hello"},
+		{name: "multiple lines", input: "greet()\n{\n  echo hello\n}\ngreet", expected: "hello"},
+		{name: "positional parameters", input: "f() { echo \"$# $1 $2\"; }; f a 'b c'", expected: "2 a b c"},
+		{name: "all parameters", input: "f() { for x in \"$@\"; do echo $x; done; }; f a 'b c'", expected: "a\nb c"},
+		{name: "parameters restored", input: "set -- x y z; f() { echo $#; }; f a; echo $# $1", expected: "1\n3 x"},
+		{name: "FUNCNAME", input: "f() { echo $FUNCNAME ${FUNCNAME[@]}; }; g() { f; }; g; echo \"[$FUNCNAME]\"", expected: "f f g\n[]"},
+		{name: "status of last command", input: "f() { false; }; f; echo $?", expected: "1"},
+		{name: "return", input: "f() { return 3; echo no; }; f; echo $?", expected: "3"},
+		{name: "return last status", input: "f() { false; return; }; f; echo $?", expected: "1"},
+		{name: "return from loop", input: "f() { for x in a b c; do [[ $x == b ]] && return 4; echo $x; done; }; f; echo $?", expected: "a\n4"},
+		{name: "return from loop condition", input: "f() { while return 5; do echo no; done; }; f; echo $?", expected: "5"},
+		{name: "return outside function", input: "return 1 2> /dev/null; echo $?", expected: "2"},
+		{name: "global variables", input: "f() { x=inner; }; x=outer; f; echo $x", expected: "inner"},
+		{name: "local variables", input: "f() { local x=inner; echo $x; }; x=outer; f; echo $x", expected: "inner\nouter"},
+		{name: "local without value", input: "f() { local x; echo \"[$x]\"; x=set; }; x=outer; f; echo $x", expected: "[]\nouter"},
+		{name: "local unset afterwards", input: "unset x; f() { local x=1; }; f; echo \"[${x-unset}]\"", expected: "[unset]"},
+		{name: "dynamic scoping", input: "f() { local x=f; g; }; g() { echo $x; x=g; }; x=outer; f; echo $x", expected: "f\nouter"},
+		{name: "local in command substitution", input: "y=global; f() { echo $(local y=2; echo $y); y=changed; }; f; echo $y", expected: "2\nchanged"},
+		{name: "local in pipeline", input: "b=outer; f() { local a=1; local b=2 | local b=3; echo $a $b; }; f; echo $b", expected: "1 outer\nouter"},
+		{name: "local outside function", input: "local x 2> /dev/null; echo $?", expected: "1"},
+		{name: "local readonly", input: "readonly r=1; f() { local r=2; }; f 2> /dev/null; echo $? $r", expected: "1 1"},
+		{name: "recursion", input: "fact() { if (( $1 <= 1 )); then echo 1; else echo $(( $1 * $(fact $(( $1 - 1 ))) )); fi; }; fact 5", expected: "120"},
+		{name: "function before builtin", input: "echo() { printf '<%s>' \"$@\"; }; echo a b", expected: "<a><b>"},
+		{name: "unset function", input: "echo() { printf x; }; unset -f echo; echo builtin", expected: "builtin"},
+		{name: "redefine", input: "f() { echo 1; }; f() { echo 2; }; f", expected: "2"},
+		{name: "in pipeline", input: "f() { echo b; echo a; }; f | sort", expected: "a\nb"},
+		{name: "redirection of body", input: "f() { echo out; echo err >&2; } 2>&1; f 2> /dev/null", expected: "out\nerr"},
+		{name: "temporary assignment", input: "f() { echo $V; }; V=temp f; echo \"[$V]\"", expected: "temp\n[]"},
+		{name: "break does not leave caller loop", input: "f() { break 2> /dev/null; }; for x in a b; do f; echo $x; done", expected: "a\nb"},
+		{name: "defined in subshell", input: "echo $(f() { echo inner; }; f); f 2> /dev/null; echo $?", expected: "inner\n127"},
+		{name: "type", input: "f() { echo hi; }; type f", expected: "f is a function\nf() { echo hi; }"},
+		{name: "brace group", input: "{ echo a; echo b; } | tr a-z A-Z", expected: "A\nB"},
+		{name: "brace group in current shell", input: "{ x=1; }; echo $x", expected: "1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// The group collects the output of every command in the input
+			if got := runLineOutput(t, newTestConfig(), "{ "+tc.input+"\n}"); got != tc.expected {
+				t.Fatalf("expected: %#v, got: %#v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	script := "#!/usr/bin/env bitbash\n" +
+		"# Report the arguments\n" +
+		"if [[ $# -gt 1 ]]\n" +
+		"then\n" +
+		"\techo \"$0: $@\" > out.txt\n" +
+		"fi\n" +
+		"echo one \\\n" +
+		"  two >> out.txt\n" +
+		"[[ $1 == b ]]\n"
+	os.WriteFile("script.sh", []byte(script), 0o644)
+
+	cfg := newTestConfig()
+	if status := RunScript(cfg, "script.sh", []string{"a", "b"}); status != 1 {
+		t.Fatalf("expected status 1, got %d", status)
+	}
+
+	content, _ := os.ReadFile("out.txt")
+	if got := string(content); got != "script.sh: a b\none two\n" {
+		t.Fatalf("expected: %#v, got: %#v", "script.sh: a b\none two\n", got)
+	}
+}
@@ -0,0 +1,572 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// expansion collects the fields that result from expanding a word. Quoted
+// text is kept escaped with EscapePattern until the fields are finished so
+// that it can still be told apart from unquoted text.
+type expansion struct {
+	cfg      *Config
+	stdio    *Stdio
+	split    bool
+	fields   []*field
+	newField bool
+}
+
+type field struct {
+	text strings.Builder
+	// A field with quoted text, or that ends in a non-whitespace IFS
+	// character, is kept even when it is empty
+	keep bool
+}
+
+// ExpandWords performs brace expansion, tilde expansion, parameter
+// expansion, command substitution, field splitting, pathname expansion and
+// quote removal on words and returns the resulting fields.
+func (cfg *Config) ExpandWords(words []*Word, stdio *Stdio) ([]string, error) {
+	var fields []string
+
+	var expanded []*Word
+	for _, word := range words {
+		for _, braced := range BraceExpand(word) {
+			expanded = append(expanded, cfg.TildeExpand(braced, false))
+		}
+	}
+
+	for _, word := range expanded {
+		e := &expansion{cfg: cfg, stdio: stdio, split: true}
+		if err := e.expandParts(word.Parts, false); err != nil {
+			return nil, err
+		}
+
+		for _, f := range e.fields {
+			if f.text.Len() == 0 && !f.keep {
+				continue
+			}
+
+			pattern := f.text.String()
+			if !HasGlobChars(pattern) {
+				fields = append(fields, unescapePattern(pattern))
+				continue
+			}
+
+			// A pattern that matches nothing is kept as it is unless the
+			// nullglob or failglob option is on
+			matches := cfg.Glob(pattern)
+			switch {
+			case len(matches) > 0:
+				fields = append(fields, matches...)
+			case cfg.Options["failglob"]:
+				return nil, fmt.Errorf("no match: %s", unescapePattern(pattern))
+			case !cfg.Options["nullglob"]:
+				fields = append(fields, unescapePattern(pattern))
+			}
+		}
+	}
+
+	return fields, nil
+}
+
+// ExpandWord expands word like ExpandWords but without splitting it into
+// fields and returns the resulting string.
+func (cfg *Config) ExpandWord(word *Word, stdio *Stdio) (string, error) {
+	pattern, err := cfg.ExpandPattern(word, stdio)
+	return unescapePattern(pattern), err
+}
+
+// ExpandRedirect expands the target of a redirection. Here-documents
+// expand to their body and here-strings to the word followed by a newline,
+// neither of which is split into fields.
+func (cfg *Config) ExpandRedirect(redirect *Redirect, stdio *Stdio) (string, error) {
+	switch redirect.Op {
+	case "<<", "<<-":
+		return cfg.ExpandWord(redirect.Body, stdio)
+	case "<<<":
+		word := cfg.TildeExpand(redirect.Target, false)
+		text, err := cfg.ExpandWord(&Word{Parts: []WordPart{&DoubleQuoted{Parts: word.Parts}}}, stdio)
+		return text + "\n", err
+	}
+	return cfg.ExpandWord(cfg.TildeExpand(redirect.Target, false), stdio)
+}
+
+// ExpandPattern expands word like ExpandWord but leaves any text that was
+// quoted escaped, so that only unquoted characters have a special meaning
+// when the result is used as a pattern.
+func (cfg *Config) ExpandPattern(word *Word, stdio *Stdio) (string, error) {
+	e := &expansion{cfg: cfg, stdio: stdio}
+	if err := e.expandParts(word.Parts, false); err != nil {
+		return "", err
+	}
+
+	if len(e.fields) == 0 {
+		return "", nil
+	}
+	return e.fields[0].text.String(), nil
+}
+
+func (e *expansion) current() *field {
+	if len(e.fields) == 0 || e.newField {
+		e.fields = append(e.fields, &field{})
+		e.newField = false
+	}
+	return e.fields[len(e.fields)-1]
+}
+
+func (e *expansion) write(text string, quoted bool) {
+	f := e.current()
+	if quoted {
+		f.keep = true
+		f.text.WriteString(EscapePattern(text))
+	} else {
+		f.text.WriteString(strings.ReplaceAll(text, `\`, `\\`))
+	}
+}
+
+// writeExpanded adds the result of an expansion. When it is unquoted it is
+// split into fields on the characters of IFS: runs of IFS whitespace
+// separate fields and are dropped at the start and end of the value, while
+// every other IFS character ends a field, which may be empty.
+func (e *expansion) writeExpanded(value string, quoted bool) {
+	ifs := e.cfg.IFS()
+	if quoted || !e.split || ifs == "" {
+		e.write(value, quoted)
+		return
+	}
+
+	rest := trimIFSWhitespace(value, ifs)
+	if len(rest) < len(value) {
+		e.newField = true
+	}
+
+	for rest != "" {
+		var text string
+		var delimited bool
+		text, rest, delimited = cutIFSField(rest, ifs)
+
+		e.write(text, false)
+		if delimited {
+			e.current().keep = true
+			e.newField = true
+		}
+	}
+}
+
+// writeList adds the elements of "$@", "$*" or an array expanded with
+// [@] or [*]. Quoted, '@' makes each element a separate field and '*'
+// joins them with the first character of IFS. Unquoted, each element is
+// split into fields on its own.
+func (e *expansion) writeList(values []string, quoted, star bool) {
+	if quoted && star || !e.split {
+		sep := " "
+		if star {
+			sep = e.cfg.IFS()
+			if len(sep) > 1 {
+				sep = sep[:1]
+			}
+		}
+		e.write(strings.Join(values, sep), quoted)
+		return
+	}
+
+	for i, value := range values {
+		if i > 0 {
+			e.newField = true
+		}
+		if quoted {
+			e.write(value, true)
+		} else {
+			e.writeExpanded(value, false)
+		}
+	}
+}
+
+// IFS returns the characters used to split fields, which are space, tab
+// and newline when IFS is unset.
+func (cfg *Config) IFS() string {
+	if ifs, ok := cfg.GetVar("IFS"); ok {
+		return ifs
+	}
+	return " \t\n"
+}
+
+func isIFSWhitespace(c byte, ifs string) bool {
+	return strings.IndexByte(" \t\n", c) != -1 && strings.IndexByte(ifs, c) != -1
+}
+
+// ifsWhitespace returns a function reporting whether a rune is IFS
+// whitespace, for use with the strings.Trim functions.
+func ifsWhitespace(ifs string) func(c rune) bool {
+	return func(c rune) bool { return c < utf8.RuneSelf && isIFSWhitespace(byte(c), ifs) }
+}
+
+func trimIFSWhitespace(s, ifs string) string {
+	return strings.TrimLeftFunc(s, ifsWhitespace(ifs))
+}
+
+// cutIFSField returns the text of s up to the first IFS character and the
+// rest of s after the delimiter, made of IFS whitespace around at most one
+// other IFS character. delimited is false if s has no IFS character.
+func cutIFSField(s, ifs string) (text, rest string, delimited bool) {
+	end := strings.IndexAny(s, ifs)
+	if end == -1 {
+		return s, "", false
+	}
+
+	rest = trimIFSWhitespace(s[end:], ifs)
+	if rest != "" && !isIFSWhitespace(rest[0], ifs) && strings.IndexByte(ifs, rest[0]) != -1 {
+		rest = trimIFSWhitespace(rest[1:], ifs)
+	}
+	return s[:end], rest, true
+}
+
+func (e *expansion) expandParts(parts []WordPart, quoted bool) error {
+	for _, part := range parts {
+		switch p := part.(type) {
+		case *Literal:
+			e.write(p.Text, quoted || p.Quoted)
+		case *DoubleQuoted:
+			// "$@" without any positional parameters expands to nothing,
+			// otherwise double quotes always make a field
+			if !hasListParam(p.Parts) {
+				e.current().keep = true
+			}
+			if err := e.expandParts(p.Parts, true); err != nil {
+				return err
+			}
+		case *ParamExp:
+			if values, star, ok := e.cfg.listParam(p); ok {
+				e.writeList(values, quoted, star)
+				continue
+			}
+
+			value, operand, err := e.expandParam(p)
+			if err != nil {
+				return err
+			}
+
+			if operand != nil {
+				if err := e.expandOperand(operand.Parts, quoted); err != nil {
+					return err
+				}
+			} else {
+				e.writeExpanded(value, quoted)
+			}
+		case *CommandSub:
+			output, err := e.cfg.substituteCommand(p.List, e.stdio)
+			if err != nil {
+				return err
+			}
+			e.writeExpanded(output, quoted)
+		case *ProcessSub:
+			path, err := e.cfg.substituteProcess(p, e.stdio)
+			if err != nil {
+				return err
+			}
+			e.write(path, true)
+		case *ArithExp:
+			value, err := e.cfg.ExpandArith(p.Expr, e.stdio)
+			if err != nil {
+				return err
+			}
+			e.writeExpanded(strconv.FormatInt(value, 10), quoted)
+		}
+	}
+	return nil
+}
+
+// expandOperand adds the word used in place of a parameter, as with
+// ${VAR:-word}. Its text is the result of the expansion, so unless it was
+// quoted it is split into fields like a value.
+func (e *expansion) expandOperand(parts []WordPart, quoted bool) error {
+	for _, part := range parts {
+		if lit, ok := part.(*Literal); ok && !lit.Quoted {
+			e.writeExpanded(lit.Text, quoted)
+			continue
+		}
+		if err := e.expandParts([]WordPart{part}, quoted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExpandArith expands the parameters and command substitutions in expr
+// and evaluates the result as an arithmetic expression.
+func (cfg *Config) ExpandArith(expr *Word, stdio *Stdio) (int64, error) {
+	text, err := cfg.ExpandWord(expr, stdio)
+	if err != nil {
+		return 0, err
+	}
+	return cfg.EvalArith(text)
+}
+
+// substituteCommand runs list in a subshell and returns its output with
+// any trailing newlines removed. Its status becomes the value of $?.
+func (cfg *Config) substituteCommand(list *List, stdio *Stdio) (string, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+
+	output := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		r.Close()
+		output <- data
+	}()
+
+	cfg.SubstStatus = cfg.RunSubshell(list, &Stdio{In: stdio.In, Out: w, Err: stdio.Err})
+	cfg.LastStatus = cfg.SubstStatus
+	w.Close()
+
+	return strings.TrimRight(string(<-output), "\n"), nil
+}
+
+// ProcSub is a running process substitution. File is the shell's end of
+// the pipe and Done is closed once the commands inside have finished.
+type ProcSub struct {
+	File *os.File
+	Done chan struct{}
+}
+
+// substituteProcess starts the commands of sub in a subshell connected to
+// a pipe and returns the path of the shell's end of the pipe. The process
+// substitution is added to cfg.ProcSubs so that the command it was
+// expanded for can pass the pipe on and clean up after it.
+func (cfg *Config) substituteProcess(sub *ProcessSub, stdio *Stdio) (string, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+
+	inner := &Stdio{In: stdio.In, Out: w, Err: stdio.Err}
+	file, other := r, w
+	if sub.Output {
+		inner.In, inner.Out = r, stdio.Out
+		file, other = w, r
+	}
+
+	// The shell carries on while the commands run, so the subshell's copy
+	// of its state has to be made now
+	subshell := cfg.Subshell()
+
+	done := make(chan struct{})
+	go func() {
+		subshell.RunSubshell(sub.List, inner)
+		other.Close()
+		close(done)
+	}()
+
+	cfg.ProcSubs = append(cfg.ProcSubs, &ProcSub{File: file, Done: done})
+	return "/dev/fd/" + strconv.Itoa(int(file.Fd())), nil
+}
+
+// expandParam returns the value of a parameter expansion. The "-" and "+"
+// operators can instead return their operand word, which is then expanded
+// in place so that its quoting is preserved.
+func (e *expansion) expandParam(param *ParamExp) (string, *Word, error) {
+	cfg := e.cfg
+	value, set := cfg.paramValue(param)
+
+	// The operand words start with a tilde prefix like any other word
+	if param.Word != nil {
+		expanded := *param
+		expanded.Word = cfg.TildeExpand(param.Word, false)
+		if param.Replace != nil {
+			expanded.Replace = cfg.TildeExpand(param.Replace, false)
+		}
+		param = &expanded
+	}
+
+	if param.Length {
+		if param.Index == "@" || param.Index == "*" {
+			return strconv.Itoa(len(cfg.LookupArray(param.Name))), nil, nil
+		}
+		return strconv.Itoa(utf8.RuneCountInString(value)), nil, nil
+	}
+
+	switch param.Op {
+	case ":-", "-", ":=", "=", ":?", "?", ":+", "+":
+		// With a colon a parameter that is set but empty is treated
+		// the same as one that is unset
+		missing := !set || (strings.HasPrefix(param.Op, ":") && value == "")
+
+		switch strings.TrimPrefix(param.Op, ":") {
+		case "-":
+			if missing {
+				return "", param.Word, nil
+			}
+		case "=":
+			if missing {
+				value, err := e.assignDefault(param)
+				return value, nil, err
+			}
+		case "?":
+			if missing {
+				msg, err := cfg.ExpandWord(param.Word, e.stdio)
+				if err != nil {
+					return "", nil, err
+				}
+				if msg == "" {
+					msg = "parameter null or not set"
+				}
+				return "", nil, fmt.Errorf("%s: %s", param.Name, msg)
+			}
+		case "+":
+			if missing {
+				return "", nil, nil
+			}
+			return "", param.Word, nil
+		}
+		return value, nil, nil
+
+	case "#", "##", "%", "%%":
+		pattern, err := cfg.ExpandPattern(param.Word, e.stdio)
+		if err != nil {
+			return "", nil, err
+		}
+		return TrimPattern(value, CompilePattern(pattern, cfg.Options["extglob"]), param.Op), nil, nil
+
+	case "/", "//", "/#", "/%":
+		pattern, err := cfg.ExpandPattern(param.Word, e.stdio)
+		if err != nil {
+			return "", nil, err
+		}
+
+		replacement := ""
+		if param.Replace != nil {
+			if replacement, err = cfg.ExpandWord(param.Replace, e.stdio); err != nil {
+				return "", nil, err
+			}
+		}
+		return ReplacePattern(value, CompilePattern(pattern, cfg.Options["extglob"]), replacement, param.Op), nil, nil
+	}
+
+	return value, nil, nil
+}
+
+// assignDefault handles ${NAME:=word} by assigning the expanded word to
+// NAME and returning it.
+func (e *expansion) assignDefault(param *ParamExp) (string, error) {
+	cfg := e.cfg
+	value, err := cfg.ExpandWord(param.Word, e.stdio)
+	if err != nil {
+		return "", err
+	}
+
+	if param.Index != "" || !IsValidName(param.Name) {
+		return "", fmt.Errorf("$%s: cannot assign in this way", param.Name)
+	}
+	if err := cfg.SetVar(param.Name, value); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// listParam returns the elements of a parameter that expands to a list,
+// $@ and $* or an array subscripted with [@] or [*], and whether the
+// elements are joined as with '*'. ok is false for any other parameter.
+func (cfg *Config) listParam(param *ParamExp) (values []string, star, ok bool) {
+	if param.Length || param.Op != "" {
+		return nil, false, false
+	}
+
+	switch {
+	case param.Index == "" && (param.Name == "@" || param.Name == "*"):
+		return cfg.Positional, param.Name == "*", true
+	case param.Index == "@" || param.Index == "*":
+		return cfg.LookupArray(param.Name), param.Index == "*", true
+	}
+	return nil, false, false
+}
+
+func hasListParam(parts []WordPart) bool {
+	for _, part := range parts {
+		if param, ok := part.(*ParamExp); ok && param.Op == "" && !param.Length &&
+			((param.Name == "@" && param.Index == "") || param.Index == "@") {
+			return true
+		}
+	}
+	return false
+}
+
+// paramValue returns the value of the parameter, or of the array element
+// selected by its subscript, and whether it is set.
+func (cfg *Config) paramValue(param *ParamExp) (string, bool) {
+	if param.Index == "" {
+		return cfg.LookupParam(param.Name)
+	}
+
+	values := cfg.LookupArray(param.Name)
+	if param.Index == "@" || param.Index == "*" {
+		return strings.Join(values, " "), len(values) > 0
+	}
+
+	i, err := strconv.Atoi(param.Index)
+	if err != nil || i < 0 || i >= len(values) {
+		return "", false
+	}
+	return values[i], true
+}
+
+// LookupParam returns the value of the parameter name and whether it is set.
+// For arrays this is the value of the first element.
+func (cfg *Config) LookupParam(name string) (string, bool) {
+	if n, err := strconv.Atoi(name); err == nil {
+		if n == 0 {
+			return cfg.ShellName, true
+		}
+		if n > len(cfg.Positional) {
+			return "", false
+		}
+		return cfg.Positional[n-1], true
+	}
+
+	switch name {
+	case "?":
+		return strconv.Itoa(cfg.LastStatus), true
+	case "#":
+		return strconv.Itoa(len(cfg.Positional)), true
+	case "@", "*":
+		return strings.Join(cfg.Positional, " "), len(cfg.Positional) > 0
+	case "PIPESTATUS":
+		if len(cfg.PipeStatus) == 0 {
+			return "", false
+		}
+		return strconv.Itoa(cfg.PipeStatus[0]), true
+	case "FUNCNAME":
+		if len(cfg.FuncNames) == 0 {
+			return "", false
+		}
+		return cfg.FuncNames[0], true
+	}
+	return cfg.GetVar(name)
+}
+
+// LookupArray returns the elements of the array name. A set parameter that
+// is not an array is treated as an array with a single element.
+func (cfg *Config) LookupArray(name string) []string {
+	switch name {
+	case "PIPESTATUS":
+		values := make([]string, 0, len(cfg.PipeStatus))
+		for _, status := range cfg.PipeStatus {
+			values = append(values, strconv.Itoa(status))
+		}
+		return values
+	case "FUNCNAME":
+		return slices.Clone(cfg.FuncNames)
+	}
+
+	if value, ok := cfg.LookupParam(name); ok {
+		return []string{value}
+	}
+	return nil
+}
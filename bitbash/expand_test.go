@@ -0,0 +1,244 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParameterExpansion(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "plain", input: "$FILE", expected: "src/main.tar.gz"},
+		{name: "braced", input: "${FILE}x", expected: "src/main.tar.gzx"},
+		{name: "unset", input: "[$UNSET]", expected: "[]"},
+		{name: "single quotes are literal", input: "'$FILE ${FILE}'", expected: "$FILE ${FILE}"},
+		{name: "double quotes expand", input: `"<$FILE>"`, expected: "<src/main.tar.gz>"},
+		{name: "escaped dollar", input: `\$FILE "\$FILE"`, expected: "$FILE $FILE"},
+		{name: "lone dollar", input: "$ a$ $", expected: "$ a$ $"},
+
+		{name: "default when unset", input: "${UNSET:-default}", expected: "default"},
+		{name: "default when empty", input: "${EMPTY:-default}", expected: "default"},
+		{name: "no default when set", input: "${FILE:-default}", expected: "src/main.tar.gz"},
+		{name: "default without colon when empty", input: "[${EMPTY-default}]", expected: "[]"},
+		{name: "default without colon when unset", input: "${UNSET-default}", expected: "default"},
+		{name: "default is expanded", input: "${UNSET:-$EMPTY${FILE}}", expected: "src/main.tar.gz"},
+		{name: "quoted default", input: `${UNSET:-"a  b"}`, expected: "a  b"},
+		{name: "nested default", input: "${UNSET:-${ALSO_UNSET:-inner}}", expected: "inner"},
+		{name: "alternative when set", input: "${FILE:+alt}", expected: "alt"},
+		{name: "alternative when empty", input: "[${EMPTY:+alt}]", expected: "[]"},
+		{name: "alternative without colon when empty", input: "${EMPTY+alt}", expected: "alt"},
+		{name: "length", input: "${#FILE}", expected: "15"},
+		{name: "length of multibyte", input: "${#ACCENT}", expected: "4"},
+		{name: "length of unset", input: "${#UNSET}", expected: "0"},
+
+		{name: "shortest prefix", input: "${FILE#*/}", expected: "main.tar.gz"},
+		{name: "longest prefix", input: "${FILE##*.}", expected: "gz"},
+		{name: "shortest suffix", input: "${FILE%.*}", expected: "src/main.tar"},
+		{name: "longest suffix", input: "${FILE%%.*}", expected: "src/main"},
+		{name: "no match", input: "${FILE#x}", expected: "src/main.tar.gz"},
+		{name: "bracket pattern", input: "${FILE##*[./]}", expected: "gz"},
+		{name: "quoted pattern is literal", input: `${STARS#"*"}`, expected: "a**b"},
+		{name: "unquoted pattern", input: `${STARS#*a}`, expected: "**b"},
+		{name: "quoted pattern in double quotes", input: `"${STARS%"*b"}"`, expected: "*a*"},
+		{name: "pattern from variable", input: `${FILE%$EXT}`, expected: "src/main.tar"},
+
+		{name: "replace first", input: "${PATH_LIST/:/ }", expected: "/bin /usr/bin:/sbin"},
+		{name: "replace all", input: "${PATH_LIST//:/ }", expected: "/bin /usr/bin /sbin"},
+		{name: "replace longest match", input: "${FILE/m*./X}", expected: "src/Xgz"},
+		{name: "replace prefix", input: "${FILE/#src/lib}", expected: "lib/main.tar.gz"},
+		{name: "replace suffix", input: "${FILE/%gz/xz}", expected: "src/main.tar.xz"},
+		{name: "replace anchored no match", input: "${FILE/#main/x}", expected: "src/main.tar.gz"},
+		{name: "delete matches", input: "${PATH_LIST//:}", expected: "/bin/usr/bin/sbin"},
+		{name: "replace with expansion", input: "${FILE/main/$EXT}", expected: "src/.gz.tar.gz"},
+
+		{name: "assign default", input: "${NEW:=value} $NEW", expected: "value value"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			runLine(t, cfg, `FILE=src/main.tar.gz EMPTY= STARS='*a**b' EXT=.gz ACCENT=café PATH_LIST=/bin:/usr/bin:/sbin`)
+
+			if got := runLineOutput(t, cfg, "echo "+tc.input); got != tc.expected {
+				t.Fatalf("expected: %#v, got: %#v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestParameterExpansionErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+		parse bool
+	}{
+		{name: "error when unset", input: "echo ${UNSET:?not set}"},
+		{name: "error when empty", input: "EMPTY=; echo ${EMPTY:?}"},
+		{name: "assign to readonly", input: "readonly RO=; echo ${RO:=x}"},
+		{name: "bad substitution", input: "echo ${FOO:x}", parse: true},
+		{name: "missing name", input: "echo ${}", parse: true},
+		{name: "unclosed brace", input: "echo ${FOO", parse: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Parse(tc.input); (err != nil) != tc.parse {
+				t.Fatalf("expected parse error: %v, got: %v", tc.parse, err)
+			}
+			if tc.parse {
+				return
+			}
+
+			if status := runLine(t, newTestConfig(), tc.input); status != 1 {
+				t.Fatalf("expected status 1, got %d", status)
+			}
+		})
+	}
+}
+
+func TestCommandSubstitution(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "dollar paren", input: `echo "built at $(echo noon)"`, expected: "built at noon"},
+		{name: "backquotes", input: "echo `echo hi`", expected: "hi"},
+		{name: "trailing newlines removed", input: `echo "[$(printf 'a\n\n\n')]"`, expected: "[a]"},
+		{name: "inner newlines kept when quoted", input: `echo "$(echo a; echo b)"`, expected: "a\nb"},
+		{name: "unquoted result is split", input: `printf '<%s>' $(echo "a  b")`, expected: "<a><b>"},
+		{name: "quoted result is not split", input: `printf '<%s>' "$(echo "a  b")"`, expected: "<a  b>"},
+		{name: "split joins surrounding text", input: `printf '<%s>' x$(echo "a b")y`, expected: "<xa><by>"},
+		{name: "empty unquoted result is removed", input: `printf '<%s>' a $(true) b`, expected: "<a><b>"},
+		{name: "empty quoted result is kept", input: `printf '<%s>' a "$(true)" b`, expected: "<a><><b>"},
+		{name: "nested", input: "echo $(echo $(echo deep))", expected: "deep"},
+		{name: "nested backquotes", input: "echo `echo \\`echo hi\\``", expected: "hi"},
+		{name: "pipeline inside", input: "echo $(echo abc | tr a-z A-Z)", expected: "ABC"},
+		{name: "quotes inside", input: `echo "$(echo "inner quotes")"`, expected: "inner quotes"},
+		{name: "parenthesis inside quotes", input: `echo $(echo ')')`, expected: ")"},
+		{name: "single quotes are literal", input: `echo '$(echo hi)'`, expected: "$(echo hi)"},
+		{name: "assignment", input: "x=$(echo value); echo $x", expected: "value"},
+		{name: "variables do not leak", input: "echo $(y=1; echo $y) [$y]", expected: "1 []"},
+		{name: "exit only leaves substitution", input: "echo $(echo a; exit 3; echo b) $?", expected: "a 3"},
+		{name: "status of assignment", input: "x=$(false); echo $?", expected: "1"},
+		{name: "working directory is restored", input: "cd /; echo $(cd /tmp; pwd) $(pwd)", expected: "/tmp /"},
+		{name: "relative cd", input: "cd /; echo $(cd usr; cd bin; pwd) $PWD", expected: "/usr/bin /"},
+		{name: "commands use working directory", input: "cd /; echo $(cd /usr; sh -c pwd; [[ -d share ]] && echo share)", expected: "/usr share"},
+		{name: "globs use working directory", input: "cd /; echo $(cd /usr; echo shar*)", expected: "share"},
+		{name: "redirections use working directory", input: "cd /; echo $(cd /etc; cat < passwd | grep -c '^root:')", expected: "1"},
+	}
+
+	dir, _ := os.Getwd()
+	defer os.Chdir(dir)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := runLineOutput(t, newTestConfig(), tc.input); got != tc.expected {
+				t.Fatalf("expected: %#v, got: %#v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestProcessSubstitution(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "read from command", input: "cat <(echo one) <(echo two)", expected: "one\ntwo"},
+		{name: "compare outputs", input: `diff <(printf 'a\nb\n') <(printf 'a\nc\n') | grep -c '^[<>]'`, expected: "2"},
+		{name: "replaced by path", input: "echo <(true) | grep -c '^/dev/fd/[0-9]*$'", expected: "1"},
+		{name: "write to command", input: "echo hi > >(tr a-z A-Z > upper.txt); cat upper.txt", expected: "HI"},
+		{name: "redirect builtin", input: "read x < <(echo value); echo $x", expected: "value"},
+		{name: "command stops reading", input: "head -n 1 <(yes)", expected: "y"},
+		{name: "quoted is literal", input: `echo "<(true)"`, expected: "<(true)"},
+		{name: "separate working directories", input: "cd /tmp; cat <(cd /; sleep .2; pwd) <(sleep .1; pwd)", expected: "/\n/tmp"},
+	}
+
+	t.Chdir(t.TempDir())
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := runLineOutput(t, newTestConfig(), tc.input); got != tc.expected {
+				t.Fatalf("expected: %#v, got: %#v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestFieldSplitting(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "default IFS", input: `V='  a  b	c '; printf '<%s>' $V`, expected: "<a><b><c>"},
+		{name: "non-whitespace delimiter", input: `IFS=:; V=a:b:c; printf '<%s>' $V`, expected: "<a><b><c>"},
+		{name: "empty fields kept", input: `IFS=:; V=a::b; printf '<%s>' $V`, expected: "<a><><b>"},
+		{name: "leading delimiter", input: `IFS=:; V=:a; printf '<%s>' $V`, expected: "<><a>"},
+		{name: "trailing delimiter", input: `IFS=:; V=a:; printf '<%s>' $V`, expected: "<a>"},
+		{name: "whitespace around delimiter", input: `IFS=' :'; V=' a : b  c '; printf '<%s>' $V`, expected: "<a><b><c>"},
+		{name: "joins surrounding text", input: `IFS=:; V=a:b; printf '<%s>' x${V}y`, expected: "<xa><by>"},
+		{name: "empty IFS", input: `IFS=; V='a b'; printf '<%s>' $V`, expected: "<a b>"},
+		{name: "unset IFS", input: `IFS=:; unset IFS; V='a b'; printf '<%s>' $V`, expected: "<a><b>"},
+		{name: "quoted not split", input: `IFS=:; V=a:b; printf '<%s>' "$V"`, expected: "<a:b>"},
+		{name: "literal text not split", input: `IFS=:; printf '<%s>' a:b`, expected: "<a:b>"},
+		{name: "command substitution", input: `IFS=,; printf '<%s>' $(echo 1,2)`, expected: "<1><2>"},
+		{name: "assignment not split", input: `IFS=:; V=a:b; W=$V; printf '<%s>' "$W"`, expected: "<a:b>"},
+		{name: "default value", input: `printf '<%s>' ${UNSET:-one two  three}`, expected: "<one><two><three>"},
+		{name: "assigned default value", input: `printf '<%s>' ${NEW:=a b} "[$NEW]"`, expected: "<a><b><[a b]>"},
+		{name: "alternate value", input: `D=1; printf '<%s>' ${D:+p q}`, expected: "<p><q>"},
+		{name: "default value with IFS", input: `IFS=:; printf '<%s>' ${UNSET:-a:b}`, expected: "<a><b>"},
+		{name: "quoted default value", input: `printf '<%s>' ${UNSET:-"a b"} "${UNSET:-c d}"`, expected: "<a b><c d>"},
+		{name: "default value joins surrounding text", input: `printf '<%s>' x${UNSET:-a b}y`, expected: "<xa><by>"},
+		{name: "for loop over default value", input: `for x in ${ITEMS:-one two three}; do printf '<%s>' $x; done`, expected: "<one><two><three>"},
+		{name: "set positional parameters", input: `set -- ${UNSET:-a b}; printf '<%s>' $# "$1"`, expected: "<2><a>"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := runLineOutput(t, newTestConfig(), tc.input); got != tc.expected {
+				t.Fatalf("expected: %#v, got: %#v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestPositionalParameters(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "count", input: "echo $#", expected: "4"},
+		{name: "by number", input: "echo $1 $2 $4", expected: "a b c d"},
+		{name: "unbraced number is one digit", input: "echo $10", expected: "a b0"},
+		{name: "braced number", input: "echo [${5}] ${1:-x}", expected: "[] a b"},
+		{name: "quoted at", input: `printf '<%s>' "$@"`, expected: "<a b><c><><d>"},
+		{name: "quoted at with text", input: `printf '<%s>' "x$@y"`, expected: "<xa b><c><><dy>"},
+		{name: "quoted star", input: `printf '<%s>' "$*"`, expected: "<a b c  d>"},
+		{name: "quoted star with IFS", input: `IFS=-; printf '<%s>' "$*"`, expected: "<a b-c--d>"},
+		{name: "unquoted at", input: `printf '<%s>' $@`, expected: "<a><b><c><d>"},
+		{name: "unquoted star", input: `printf '<%s>' $*`, expected: "<a><b><c><d>"},
+		{name: "no parameters", input: `set --; printf '<%s>' "$@" x`, expected: "<x>"},
+		{name: "no parameters with empty string", input: `set --; printf '<%s>' "$@"""`, expected: "<>"},
+		{name: "set without dashes", input: "set x y; echo $# $2", expected: "2 y"},
+		{name: "assignment joins", input: `V="$@"; printf '<%s>' "$V"`, expected: "<a b c  d>"},
+		{name: "array at", input: `false | true; printf '<%s>' "${PIPESTATUS[@]}"`, expected: "<1><0>"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			runLine(t, cfg, `set -- "a b" c "" d`)
+
+			if got := runLineOutput(t, cfg, tc.input); got != tc.expected {
+				t.Fatalf("expected: %#v, got: %#v", tc.expected, got)
+			}
+		})
+	}
+}
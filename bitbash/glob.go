@@ -0,0 +1,144 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"strings"
+)
+
+// HasGlobChars reports whether pattern contains an unescaped '*', '?',
+// '[' or extglob group and so may match more than the single path it
+// spells out.
+func HasGlobChars(pattern string) bool {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '*', '?', '[':
+			return true
+		case '+', '@', '!':
+			if i+1 < len(pattern) && pattern[i+1] == '(' {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Glob returns the sorted paths that match pattern. Each component of the
+// pattern between slashes is matched against the entries of the
+// directories matched so far. Names starting with '.' are only matched by
+// a component that starts with '.' unless the dotglob option is on. With
+// the globstar option a "**" component matches any number of directories,
+// or every file and directory below them when it is the last component.
+func (cfg *Config) Glob(pattern string) []string {
+	paths := []string{""}
+	components := strings.Split(pattern, "/")
+
+	if strings.HasPrefix(pattern, "/") {
+		paths = []string{"/"}
+		components = components[1:]
+	}
+
+	for i, component := range components {
+		var next []string
+		for _, dir := range paths {
+			switch {
+			case component != "**" || !cfg.Options["globstar"]:
+				next = append(next, cfg.globDir(dir, component)...)
+			case i == len(components)-1:
+				next = append(next, cfg.globTree(dir, false)...)
+			default:
+				next = append(next, dir)
+				next = append(next, cfg.globTree(dir, true)...)
+			}
+		}
+		paths = next
+	}
+
+	slices.Sort(paths)
+	return paths
+}
+
+// globDir returns the paths inside dir whose name matches component.
+func (cfg *Config) globDir(dir, component string) []string {
+	// An empty component comes from a trailing or repeated slash, which
+	// only matches directories
+	if component == "" {
+		if info, err := os.Stat(cfg.ResolvePath(dir)); err == nil && info.IsDir() && !strings.HasSuffix(dir, "/") {
+			return []string{dir + "/"}
+		}
+		return nil
+	}
+
+	if !HasGlobChars(component) {
+		path := joinPath(dir, unescapePattern(component))
+		if _, err := os.Lstat(cfg.ResolvePath(path)); err != nil {
+			return nil
+		}
+		return []string{path}
+	}
+
+	readDir := dir
+	if readDir == "" {
+		readDir = "."
+	}
+	entries, err := os.ReadDir(cfg.ResolvePath(readDir))
+	if err != nil {
+		return nil
+	}
+
+	pattern := CompilePattern(component, cfg.Options["extglob"])
+	explicitDot := strings.HasPrefix(component, ".")
+
+	var matches []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if name[0] == '.' && !explicitDot && !cfg.Options["dotglob"] {
+			continue
+		}
+		if pattern.Match(name) {
+			matches = append(matches, joinPath(dir, name))
+		}
+	}
+
+	return matches
+}
+
+// globTree returns every file and directory below dir, or only the
+// directories if dirsOnly is true. Symbolic links to directories are not
+// followed.
+func (cfg *Config) globTree(dir string, dirsOnly bool) []string {
+	readDir := dir
+	if readDir == "" {
+		readDir = "."
+	}
+	entries, err := os.ReadDir(cfg.ResolvePath(readDir))
+	if err != nil {
+		return nil
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if entry.Name()[0] == '.' && !cfg.Options["dotglob"] {
+			continue
+		}
+
+		path := joinPath(dir, entry.Name())
+		if entry.IsDir() {
+			paths = append(paths, path)
+			paths = append(paths, cfg.globTree(path, dirsOnly)...)
+		} else if !dirsOnly {
+			paths = append(paths, path)
+		}
+	}
+
+	return paths
+}
+
+func joinPath(dir, name string) string {
+	if dir == "" || strings.HasSuffix(dir, "/") {
+		return dir + name
+	}
+	return dir + "/" + name
+}
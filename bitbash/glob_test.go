@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// makeGlobTree creates a directory of files to match patterns against and
+// changes into it for the rest of the test.
+func makeGlobTree(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.md", ".hidden.txt", "sub/x.txt", "sub/deep/y.txt", "[ab].txt"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Chdir(dir)
+	return dir
+}
+
+func TestGlob(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "star", input: "echo *.txt", expected: "[ab].txt a.txt b.txt"},
+		{name: "question mark", input: "echo ?.md", expected: "c.md"},
+		{name: "bracket", input: "echo [ab].txt", expected: "a.txt b.txt"},
+		{name: "negated bracket", input: "echo [!a].*", expected: "b.txt c.md"},
+		{name: "quoted pattern", input: `echo "*.txt" '[ab]'.txt \*.md`, expected: "*.txt [ab].txt *.md"},
+		{name: "partly quoted pattern", input: `echo "["ab].txt`, expected: "[ab].txt"},
+		{name: "hidden needs explicit dot", input: "echo .*.txt", expected: ".hidden.txt"},
+		{name: "directories only", input: "echo */", expected: "sub/"},
+		{name: "across directories", input: "echo */*/*.txt", expected: "sub/deep/y.txt"},
+		{name: "literal directory", input: "echo sub/*.txt", expected: "sub/x.txt"},
+		{name: "no match kept", input: "echo *.none", expected: "*.none"},
+		{name: "unquoted variable", input: "P='*.md'; echo $P", expected: "c.md"},
+		{name: "quoted variable", input: `P='*.md'; echo "$P"`, expected: "*.md"},
+		{name: "nullglob", input: "shopt -s nullglob; echo x *.none y", expected: "x y"},
+		{name: "dotglob", input: "shopt -s dotglob; echo *.txt", expected: ".hidden.txt [ab].txt a.txt b.txt"},
+		{name: "failglob", input: "shopt -s failglob; echo *.none; echo $?", expected: "1"},
+		{name: "double star without globstar", input: "echo **/*.txt", expected: "sub/x.txt"},
+		{name: "globstar", input: "shopt -s globstar; echo **/*.txt", expected: "[ab].txt a.txt b.txt sub/deep/y.txt sub/x.txt"},
+		{name: "globstar directories", input: "shopt -s globstar; echo **/", expected: "sub/ sub/deep/"},
+		{name: "globstar everything", input: "shopt -s globstar; echo sub/**", expected: "sub/deep sub/deep/y.txt sub/x.txt"},
+		{name: "extglob", input: "shopt -s extglob; echo @(a|c).*", expected: "a.txt c.md"},
+		{name: "extglob negation", input: "shopt -s extglob; echo !(*.txt)", expected: "c.md sub"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			makeGlobTree(t)
+			cfg := newTestConfig()
+
+			if got := runLineOutput(t, cfg, tc.input); got != tc.expected {
+				t.Fatalf("expected: %#v, got: %#v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestGlobAbsolute(t *testing.T) {
+	dir := makeGlobTree(t)
+	cfg := newTestConfig()
+
+	expected := []string{filepath.Join(dir, "sub/deep/y.txt"), filepath.Join(dir, "sub/x.txt")}
+	got := cfg.Glob(EscapePattern(dir) + "/sub/*/*.txt")
+	got = append(got, cfg.Glob(EscapePattern(dir)+"/s?b/x.*")...)
+
+	if len(got) != 2 || got[0] != expected[0] || got[1] != expected[1] {
+		t.Fatalf("expected: %#v, got: %#v", expected, got)
+	}
+}
+
+func TestShopt(t *testing.T) {
+	cfg := newTestConfig()
+
+	if status := runLine(t, cfg, "shopt -q nullglob"); status != 1 {
+		t.Fatalf("expected status 1 for an option that is off, got %d", status)
+	}
+	if status := runLine(t, cfg, "shopt -s nullglob dotglob"); status != 0 || !cfg.Options["nullglob"] || !cfg.Options["dotglob"] {
+		t.Fatalf("expected options to be on, got status %d and %v", status, cfg.Options)
+	}
+	if status := runLine(t, cfg, "shopt -q nullglob dotglob"); status != 0 {
+		t.Fatalf("expected status 0 for options that are on, got %d", status)
+	}
+	if status := runLine(t, cfg, "shopt -u nullglob"); status != 0 || cfg.Options["nullglob"] {
+		t.Fatalf("expected nullglob to be off, got status %d", status)
+	}
+	if status := runLine(t, cfg, "shopt -s nosuchoption"); status != 1 {
+		t.Fatalf("expected status 1 for an invalid option, got %d", status)
+	}
+}
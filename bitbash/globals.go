@@ -25,18 +25,107 @@ const (
 	WHITE   = "\x1b[37m"
 
 	NOT_IN_HISTORY = -1
+
+	SECONDARY_PROMPT = "> "  // Shown while reading the rest of a command
+	DEFAULT_PS3      = "#? " // Shown by select when PS3 is not set
+
+	MAX_FD         = 1023 // Largest file descriptor a redirection can refer to
+	MAX_FUNC_DEPTH = 1000 // Most function calls that can be running at once
 )
 
+// Flags used to open the target of each redirection operator. The
+// operators ending in '&' duplicate a file descriptor and the here-document
+// operators feed text to the command instead of opening a file, so they
+// have no flags
 var REDIRECTION_OPS = map[string]int{
 	"<":   os.O_RDONLY,
 	">":   os.O_WRONLY | os.O_CREATE | os.O_TRUNC,
-	"1>":  os.O_WRONLY | os.O_CREATE | os.O_TRUNC,
-	"2>":  os.O_WRONLY | os.O_CREATE | os.O_TRUNC,
-	"&>":  os.O_WRONLY | os.O_CREATE | os.O_TRUNC,
 	">>":  os.O_WRONLY | os.O_CREATE | os.O_APPEND,
-	"1>>": os.O_WRONLY | os.O_CREATE | os.O_APPEND,
-	"2>>": os.O_WRONLY | os.O_CREATE | os.O_APPEND,
+	">|":  os.O_WRONLY | os.O_CREATE | os.O_TRUNC,
+	"<>":  os.O_RDWR | os.O_CREATE,
+	"&>":  os.O_WRONLY | os.O_CREATE | os.O_TRUNC,
 	"&>>": os.O_WRONLY | os.O_CREATE | os.O_APPEND,
+	"<&":  0,
+	">&":  0,
+	"<<":  0,
+	"<<-": 0,
+	"<<<": 0,
+}
+
+// Operators that separate words without surrounding spaces, longer
+// operators come first so they are preferred over their prefixes
+var OPERATORS = []string{
+	"&>>", ";;&", "<<<", "<<-",
+	"&&", "||", ";;", ";&", "|&", "<<", ">>", "<&", ">&", "<>", ">|", "&>",
+	"|", "&", ";", "<", ">", "(", ")", "\n",
+}
+
+// Reserved words that end the list of commands in front of them, as
+// `then` does in `if LIST; then LIST; fi`
+var LIST_TERMINATORS = []string{"then", "elif", "else", "fi", "do", "done", "esac", "}"}
+
+// Operators that end the body of an item of a case command
+var CASE_TERMINATORS = []string{";;", ";&", ";;&"}
+
+// Operators of parameter expansion, longer operators come first so they
+// are preferred over their prefixes
+var PARAM_OPS = []string{
+	":-", ":=", ":?", ":+", "-", "=", "?", "+",
+	"##", "#", "%%", "%", "//", "/#", "/%", "/",
+}
+
+// Operators of arithmetic expressions, longer operators come first so
+// they are preferred over their prefixes
+var ARITH_OPS = []string{
+	"<<=", ">>=", "**",
+	"++", "--", "<<", ">>", "<=", ">=", "==", "!=", "&&", "||",
+	"*=", "/=", "%=", "+=", "-=", "&=", "^=", "|=",
+	"+", "-", "*", "/", "%", "<", ">", "&", "^", "|", "!", "~",
+	"?", ":", "=", "(", ")", ",",
+}
+
+// Assignment operators of arithmetic expressions
+var ARITH_ASSIGNMENT_OPS = []string{"=", "*=", "/=", "%=", "+=", "-=", "<<=", ">>=", "&=", "^=", "|="}
+
+// Binary operators of arithmetic expressions from lowest to highest
+// precedence
+var ARITH_PRECEDENCE = [][]string{
+	{"||"},
+	{"&&"},
+	{"|"},
+	{"^"},
+	{"&"},
+	{"==", "!="},
+	{"<=", ">=", "<", ">"},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+// Operators of [[ ... ]] that test a single operand
+var COND_UNARY_OPS = []string{"-a", "-d", "-e", "-f", "-h", "-L", "-n", "-r", "-s", "-v", "-w", "-x", "-z"}
+
+// Operators of [[ ... ]] that compare two operands
+var COND_BINARY_OPS = []string{"==", "=", "!=", "<", ">", "-eq", "-ne", "-lt", "-le", "-gt", "-ge"}
+
+// Escape sequences of $'...' strings that stand for a single character
+var ANSI_ESCAPES = map[byte]byte{
+	'a': '\a', 'b': '\b', 'e': ESC, 'E': ESC, 'f': '\f', 'n': '\n',
+	'r': '\r', 't': '\t', 'v': '\v', '\\': '\\', '\'': '\'', '"': '"', '?': '?',
 }
 
 var BUILTIN_CMDS map[string]BuiltInCommand
+
+// Builtins whose NAME=value arguments are expanded like assignments
+var DECLARATION_BUILTINS = []string{"export", "local", "readonly"}
+
+// Options that can be turned on with `set -o NAME` and off with `set +o NAME`
+var SET_OPTIONS = []string{"noclobber", "pipefail"}
+
+// Single letter flags of `set` and the options they stand for
+var SET_FLAGS = map[byte]string{
+	'C': "noclobber",
+}
+
+// Options that can be turned on with `shopt -s NAME` and off with `shopt -u NAME`
+var SHOPT_OPTIONS = []string{"dotglob", "extglob", "failglob", "globstar", "nullglob"}
@@ -101,3 +101,63 @@ func TestReadLine(t *testing.T) {
 		})
 	}
 }
+
+func TestReadContinuation(t *testing.T) {
+	testCases := []struct {
+		name     string
+		first    string
+		input    string
+		expected string
+	}{
+		{
+			name:     "complete command",
+			first:    "echo hi",
+			input:    "",
+			expected: "echo hi",
+		},
+		{
+			name:     "here-document",
+			first:    "cat <<EOF",
+			input:    "one\r$x\rEOF\recho next\r",
+			expected: "cat <<EOF\none\n$x\nEOF",
+		},
+		{
+			name:     "if command",
+			first:    "if true",
+			input:    "then  echo hifi",
+			expected: "if true\nthen\n  echo hi\nfi",
+		},
+		{
+			name:     "backslash newline",
+			first:    "echo one \\",
+			input:    "  two\r",
+			expected: "echo one \\\n  two",
+		},
+		{
+			name:     "two here-documents",
+			first:    "cat <<A - <<B",
+			input:    "a\rA\rb\rB\r",
+			expected: "cat <<A - <<B\na\nA\nb\nB",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var input string
+			var err error
+
+			_ = captureStdout(func() {
+				_, input, err = ReadContinuation(&Config{
+					StdinReader: bufio.NewReader(strings.NewReader(tc.input)),
+				}, tc.first)
+			})
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if input != tc.expected {
+				t.Fatalf("expected: %#v, got: %#v", tc.expected, input)
+			}
+		})
+	}
+}
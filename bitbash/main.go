@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"os/user"
@@ -18,6 +19,27 @@ type Config struct {
 	UserName              string
 	CurrentDirectory      string
 	HomeDirectory         string
+	LastStatus            int
+	PipeStatus            []int
+	Options               map[string]bool
+	Variables             map[string]*Variable
+	SubstStatus           int
+	ProcSubs              []*ProcSub
+	ShellName             string
+	Positional            []string
+	IsSubshell            bool
+	Exiting               bool
+	ExitStatus            int
+	LoopDepth             int
+	Breaking              int
+	Continuing            int
+	Functions             map[string]*FunctionDef
+	FuncNames             []string // Functions being run, innermost first
+	// One table per function call of the variables hidden by its local
+	// variables, nil for those that were not set
+	Locals       []map[string]*Variable
+	Returning    bool
+	ReturnStatus int
 }
 
 func NewConfig() *Config {
@@ -29,10 +51,18 @@ func NewConfig() *Config {
 	cfg := &Config{
 		StdinReader:      stdin,
 		UserName:         usr.Username,
+		ShellName:        "bitbash",
 		CurrentDirectory: dir,
 		HomeDirectory:    home,
+		Options:          make(map[string]bool),
+		Variables:        VariablesFromEnviron(),
+		Functions:        make(map[string]*FunctionDef),
 	}
 
+	// PWD names the directory the shell starts in even if it was not
+	// passed in the environment
+	cfg.Variables["PWD"] = &Variable{Value: dir, Exported: true}
+
 	cfg.LoadCommandHistory()
 
 	return cfg
@@ -48,11 +78,12 @@ func (cfg *Config) RestoreTerminal() {
 }
 
 func (cfg *Config) ShellPrompt() string {
-	if cut, ok := strings.CutPrefix(cfg.CurrentDirectory, cfg.HomeDirectory); ok {
-		cfg.CurrentDirectory = fmt.Sprintf("~%s", cut)
+	currDir := cfg.CurrentDirectory
+	if cut, ok := strings.CutPrefix(currDir, cfg.HomeDirectory); ok {
+		currDir = fmt.Sprintf("~%s", cut)
 	}
 	userNameBlueBold := fmt.Sprintf("%s%s%s%s", BLUE, BOLD, cfg.UserName, RESET)
-	currDirGreenBold := fmt.Sprintf("%s%s%s%s", GREEN, BOLD, cfg.CurrentDirectory, RESET)
+	currDirGreenBold := fmt.Sprintf("%s%s%s%s", GREEN, BOLD, currDir, RESET)
 	return fmt.Sprintf("%s:%s $ ", userNameBlueBold, currDirGreenBold)
 
 	//return "$ "
@@ -110,28 +141,84 @@ func RunREPL(cfg *Config) error {
 		}
 
 		fmt.Print("\r\n")
-		cfg.RestoreTerminal()
 
 		input = strings.TrimSpace(input)
 		if len(input) == 0 {
+			cfg.RestoreTerminal()
 			continue
 		}
 
+		list, input, err := ReadContinuation(cfg, input)
+		cfg.RestoreTerminal()
+
 		cfg.History = append(cfg.History, input)
 
-		tokens, err := Tokenize(input)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "shell: %s\n", err)
+			cfg.LastStatus = 2
 			continue
 		}
 
-		NewPipeline(tokens).Execute(cfg)
+		list.Execute(cfg, NewStdio())
 	}
 }
 
+// ReadContinuation parses input, reading more lines after the secondary
+// prompt for as long as the command is incomplete. It returns the parsed
+// command and the input it was parsed from.
+func ReadContinuation(cfg *Config, input string) (*List, string, error) {
+	list, err := Parse(input)
+
+	for errors.Is(err, ErrIncomplete) {
+		fmt.Print(SECONDARY_PROMPT)
+
+		line, readErr := ReadLine(cfg)
+		if readErr != nil {
+			break
+		}
+		fmt.Print("\r\n")
+
+		input += "\n" + line
+		list, err = Parse(input)
+	}
+
+	return list, input, err
+}
+
+// RunScript runs the commands in the file at path with args as the
+// positional parameters and returns the status of the last command, or
+// the status given to `exit`.
+func RunScript(cfg *Config, path string, args []string) int {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", cfg.ShellName, err)
+		return 127
+	}
+
+	cfg.ShellName = path
+	cfg.Positional = args
+
+	list, err := Parse(string(content))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+		return 2
+	}
+
+	status := list.Execute(cfg, NewStdio())
+	if cfg.Exiting {
+		return cfg.ExitStatus
+	}
+	return status
+}
+
 func main() {
 	cfg := NewConfig()
 
+	// `bitbash SCRIPT [ARG...]` runs a script instead of the REPL
+	if len(os.Args) > 1 {
+		os.Exit(RunScript(cfg, os.Args[1], os.Args[2:]))
+	}
+
 	if err := RunREPL(cfg); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 	}
@@ -0,0 +1,896 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var ASSIGNMENT_REGEX = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+type Parser struct {
+	lexer *Lexer
+	token Token
+	// Position in the input just after the token before the current one
+	end int
+}
+
+func Parse(input string) (*List, error) {
+	p := &Parser{lexer: NewLexer(input)}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	list, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+	if p.token.Kind != TOKEN_EOF {
+		return nil, p.unexpectedToken()
+	}
+
+	return list, nil
+}
+
+// parseList parses and-or lists separated by ';' or newlines until the
+// end of the input or a token that cannot start a command, such as ')',
+// ';;' or the reserved word `then`.
+func (p *Parser) parseList() (*List, error) {
+	list := &List{}
+
+	for {
+		if err := p.skipNewlines(); err != nil {
+			return nil, err
+		}
+		if p.token.Kind == TOKEN_EOF || p.isOperator(")") || p.isListTerminator() || p.isCaseTerminator() {
+			break
+		}
+
+		andOr, err := p.parseAndOr()
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, andOr)
+
+		if !p.isOperator(";") && !p.isOperator("\n") {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	return list, nil
+}
+
+// skipNewlines advances past any newlines, which can appear wherever a
+// command is expected.
+func (p *Parser) skipNewlines() error {
+	for p.isOperator("\n") {
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseCompoundList parses a list that must contain at least one command,
+// such as the condition or the body of an `if`.
+func (p *Parser) parseCompoundList() (*List, error) {
+	list, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+	if len(list.Items) == 0 {
+		return nil, p.unexpectedToken()
+	}
+	return list, nil
+}
+
+func (p *Parser) isListTerminator() bool {
+	for _, word := range LIST_TERMINATORS {
+		if p.isWord(word) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Parser) isCaseTerminator() bool {
+	for _, op := range CASE_TERMINATORS {
+		if p.isOperator(op) {
+			return true
+		}
+	}
+	return false
+}
+
+// expectWord advances past the reserved word text or returns an error if
+// the current token is something else.
+func (p *Parser) expectWord(text string) error {
+	if !p.isWord(text) {
+		return p.unexpectedToken()
+	}
+	return p.advance()
+}
+
+func (p *Parser) advance() error {
+	p.end = p.lexer.pos
+	token, err := p.lexer.NextToken()
+	if err != nil {
+		return err
+	}
+	p.token = token
+	return nil
+}
+
+func (p *Parser) isOperator(op string) bool {
+	return p.token.Kind == TOKEN_OPERATOR && p.token.Text == op
+}
+
+func (p *Parser) unexpectedToken() error {
+	if p.token.Kind == TOKEN_EOF {
+		return fmt.Errorf("syntax error: %w", ErrIncomplete)
+	}
+	if p.isOperator("\n") {
+		return fmt.Errorf("syntax error near unexpected token 'newline'")
+	}
+	return fmt.Errorf("syntax error near unexpected token '%s'", p.token.Text)
+}
+
+func (p *Parser) parseAndOr() (*AndOrList, error) {
+	andOr := &AndOrList{}
+
+	for {
+		pipeline, err := p.parsePipeline()
+		if err != nil {
+			return nil, err
+		}
+		andOr.Pipelines = append(andOr.Pipelines, pipeline)
+
+		if !p.isOperator("&&") && !p.isOperator("||") {
+			return andOr, nil
+		}
+		andOr.Operators = append(andOr.Operators, p.token.Text)
+
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.skipNewlines(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *Parser) parsePipeline() (*PipelineNode, error) {
+	pipeline := &PipelineNode{}
+
+	for {
+		cmd, err := p.parseCommand()
+		if err != nil {
+			return nil, err
+		}
+
+		// |& also sends the standard error of the command into the pipe, as
+		// if it ended with 2>&1
+		if p.isOperator("|&") {
+			cmd = addRedirect(cmd, &Redirect{Fd: 2, Op: ">&", Target: &Word{Parts: []WordPart{&Literal{Text: "1"}}}})
+		}
+		pipeline.Commands = append(pipeline.Commands, cmd)
+
+		if !p.isOperator("|") && !p.isOperator("|&") {
+			return pipeline, nil
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.skipNewlines(); err != nil {
+			return nil, err
+		}
+		if p.token.Kind == TOKEN_EOF {
+			return nil, fmt.Errorf("missing command before/after '|'")
+		}
+	}
+}
+
+func (p *Parser) parseCommand() (CommandNode, error) {
+	var cmd CompoundCommand
+	var err error
+
+	// The text of a token is the input it was read from, so this is where
+	// the command starts
+	start := p.lexer.pos - len(p.token.Text)
+
+	switch {
+	case p.token.Kind == TOKEN_ARITH:
+		cmd = &ArithCommand{Expr: p.token.Word}
+		err = p.advance()
+	case p.isWord("[["):
+		cmd, err = p.parseCondCommand()
+	case p.isWord("if"):
+		cmd, err = p.parseIfCommand()
+	case p.isWord("while"), p.isWord("until"):
+		cmd, err = p.parseWhileCommand()
+	case p.isWord("for"):
+		cmd, err = p.parseForCommand()
+	case p.isWord("select"):
+		cmd, err = p.parseSelectCommand()
+	case p.isWord("case"):
+		cmd, err = p.parseCaseCommand()
+	case p.isWord("{"):
+		cmd, err = p.parseBraceGroup()
+	case p.isWord("function"):
+		return p.parseFunctionKeyword(start)
+	default:
+		simple, err := p.parseSimpleCommand()
+		if err != nil {
+			return nil, err
+		}
+
+		// A lone word followed by () starts a function definition
+		if p.isOperator("(") && len(simple.Words) == 1 && len(simple.Assignments) == 0 && len(simple.Redirects) == 0 {
+			return p.parseFunctionDef(start, simple.Words[0], true)
+		}
+		return simple, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return p.parseCompoundRedirects(cmd)
+}
+
+// parseCompoundRedirects parses any redirections that follow a compound
+// command.
+func (p *Parser) parseCompoundRedirects(cmd CompoundCommand) (CommandNode, error) {
+	redirected := &Redirected{Command: cmd}
+
+	for p.token.Kind == TOKEN_OPERATOR && isRedirection(p.token.Text) {
+		redirect, err := p.parseRedirect()
+		if err != nil {
+			return nil, err
+		}
+		redirected.Redirects = append(redirected.Redirects, redirect)
+
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(redirected.Redirects) == 0 {
+		return cmd, nil
+	}
+	return redirected, nil
+}
+
+// addRedirect adds redirect after the redirections of cmd.
+func addRedirect(cmd CommandNode, redirect *Redirect) CommandNode {
+	switch c := cmd.(type) {
+	case *SimpleCommand:
+		c.Redirects = append(c.Redirects, redirect)
+	case *Redirected:
+		c.Redirects = append(c.Redirects, redirect)
+	case CompoundCommand:
+		return &Redirected{Command: c, Redirects: []*Redirect{redirect}}
+	}
+	return cmd
+}
+
+// parseIfCommand parses an `if LIST; then LIST; [elif LIST; then LIST;]...
+// [else LIST;] fi` command.
+func (p *Parser) parseIfCommand() (*IfCommand, error) {
+	cmd := &IfCommand{}
+
+	for p.isWord("if") || p.isWord("elif") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		cond, err := p.parseCompoundList()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectWord("then"); err != nil {
+			return nil, err
+		}
+
+		body, err := p.parseCompoundList()
+		if err != nil {
+			return nil, err
+		}
+		cmd.Clauses = append(cmd.Clauses, &IfClause{Cond: cond, Body: body})
+	}
+
+	if p.isWord("else") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		body, err := p.parseCompoundList()
+		if err != nil {
+			return nil, err
+		}
+		cmd.Else = body
+	}
+
+	return cmd, p.expectWord("fi")
+}
+
+// parseWhileCommand parses a `while LIST; do LIST; done` command or the
+// same with `until`.
+func (p *Parser) parseWhileCommand() (*WhileCommand, error) {
+	cmd := &WhileCommand{Until: p.isWord("until")}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	cond, err := p.parseCompoundList()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Cond = cond
+
+	body, err := p.parseDoGroup()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Body = body
+
+	return cmd, nil
+}
+
+// parseForCommand parses a `for NAME [in WORD...;] do LIST; done` or a
+// `for ((INIT; COND; STEP)) do LIST; done` command.
+func (p *Parser) parseForCommand() (CompoundCommand, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	if p.token.Kind == TOKEN_ARITH {
+		return p.parseArithForCommand()
+	}
+
+	name, words, err := p.parseLoopHeader("for")
+	if err != nil {
+		return nil, err
+	}
+	cmd := &ForCommand{Name: name, Words: words}
+
+	body, err := p.parseDoGroup()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Body = body
+
+	return cmd, nil
+}
+
+// parseArithForCommand parses the rest of a for loop once the ((...))
+// holding its three expressions has been read.
+func (p *Parser) parseArithForCommand() (*ArithForCommand, error) {
+	exprs := p.token.Word.Split(';')
+	if len(exprs) != 3 {
+		return nil, fmt.Errorf("syntax error: arithmetic expression required")
+	}
+
+	cmd := &ArithForCommand{Init: exprs[0], Cond: exprs[1], Step: exprs[2]}
+	if strings.TrimSpace(cmd.Cond.Literal()) == "" {
+		cmd.Cond = nil
+	}
+
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.isOperator(";") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	body, err := p.parseDoGroup()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Body = body
+
+	return cmd, nil
+}
+
+// parseSelectCommand parses a `select NAME [in WORD...;] do LIST; done`
+// command.
+func (p *Parser) parseSelectCommand() (*SelectCommand, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	name, words, err := p.parseLoopHeader("select")
+	if err != nil {
+		return nil, err
+	}
+	cmd := &SelectCommand{Name: name, Words: words}
+
+	body, err := p.parseDoGroup()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Body = body
+
+	return cmd, nil
+}
+
+// parseLoopHeader parses the `NAME [in WORD...;]` part of a for or select
+// command. The words are nil when there is no `in`.
+func (p *Parser) parseLoopHeader(keyword string) (string, []*Word, error) {
+	if p.token.Kind != TOKEN_WORD {
+		return "", nil, p.unexpectedToken()
+	}
+
+	name := p.token.Text
+	if !p.isWord(name) || !IsValidName(name) {
+		return "", nil, fmt.Errorf("%s: '%s': not a valid identifier", keyword, name)
+	}
+
+	if err := p.advance(); err != nil {
+		return "", nil, err
+	}
+	if err := p.skipNewlines(); err != nil {
+		return "", nil, err
+	}
+
+	var words []*Word
+	switch {
+	case p.isWord("in"):
+		if err := p.advance(); err != nil {
+			return "", nil, err
+		}
+
+		words = []*Word{}
+		for p.token.Kind == TOKEN_WORD {
+			words = append(words, p.token.Word)
+			if err := p.advance(); err != nil {
+				return "", nil, err
+			}
+		}
+
+		if !p.isOperator(";") && !p.isOperator("\n") {
+			return "", nil, p.unexpectedToken()
+		}
+		if err := p.advance(); err != nil {
+			return "", nil, err
+		}
+
+	case p.isOperator(";"):
+		if err := p.advance(); err != nil {
+			return "", nil, err
+		}
+	}
+
+	return name, words, nil
+}
+
+// parseBraceGroup parses a `{ LIST; }` command.
+func (p *Parser) parseBraceGroup() (*BraceGroup, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	body, err := p.parseCompoundList()
+	if err != nil {
+		return nil, err
+	}
+
+	return &BraceGroup{Body: body}, p.expectWord("}")
+}
+
+// parseFunctionKeyword parses a `function NAME [()] COMMAND` definition
+// starting at start in the input.
+func (p *Parser) parseFunctionKeyword(start int) (*FunctionDef, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	if p.token.Kind != TOKEN_WORD {
+		return nil, p.unexpectedToken()
+	}
+	name := p.token.Word
+
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	return p.parseFunctionDef(start, name, p.isOperator("("))
+}
+
+// parseFunctionDef parses the rest of a function definition once its name
+// has been read, the () if parens is true and then the compound command
+// that is its body. start is where the definition begins in the input.
+func (p *Parser) parseFunctionDef(start int, name *Word, parens bool) (*FunctionDef, error) {
+	fn := &FunctionDef{Name: name.Literal()}
+	if !name.IsUnquoted(fn.Name) {
+		return nil, fmt.Errorf("`%s': not a valid identifier", fn.Name)
+	}
+
+	if parens {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if !p.isOperator(")") {
+			return nil, p.unexpectedToken()
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
+
+	// The body has to be a compound command such as { ...; }
+	first := p.token.Text
+	body, err := p.parseCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	switch body.(type) {
+	case *SimpleCommand, *FunctionDef:
+		return nil, fmt.Errorf("syntax error near unexpected token '%s'", first)
+	}
+	fn.Body = body
+
+	fn.Source = p.lexer.input[start:p.end]
+	return fn, nil
+}
+
+// parseCaseCommand parses a `case WORD in [(]PATTERN[|PATTERN...]) LIST
+// ;; ... esac` command. The terminator of the last item can be left out.
+func (p *Parser) parseCaseCommand() (*CaseCommand, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	if p.token.Kind != TOKEN_WORD {
+		return nil, p.unexpectedToken()
+	}
+	cmd := &CaseCommand{Word: p.token.Word}
+
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
+	if err := p.expectWord("in"); err != nil {
+		return nil, err
+	}
+
+	for {
+		if err := p.skipNewlines(); err != nil {
+			return nil, err
+		}
+		if p.isWord("esac") {
+			break
+		}
+
+		item, err := p.parseCaseItem()
+		if err != nil {
+			return nil, err
+		}
+		cmd.Items = append(cmd.Items, item)
+
+		if item.Terminator == "" {
+			break
+		}
+	}
+
+	return cmd, p.expectWord("esac")
+}
+
+// parseCaseItem parses the patterns and body of one item of a case
+// command along with the operator that ends it, if any.
+func (p *Parser) parseCaseItem() (*CaseItem, error) {
+	if p.isOperator("(") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	item := &CaseItem{}
+	for {
+		if p.token.Kind != TOKEN_WORD {
+			return nil, p.unexpectedToken()
+		}
+		item.Patterns = append(item.Patterns, p.token.Word)
+
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if !p.isOperator("|") {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if !p.isOperator(")") {
+		return nil, p.unexpectedToken()
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	body, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+	item.Body = body
+
+	if p.isCaseTerminator() {
+		item.Terminator = p.token.Text
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	return item, nil
+}
+
+// parseDoGroup parses the `do LIST; done` body of a loop.
+func (p *Parser) parseDoGroup() (*List, error) {
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
+	if err := p.expectWord("do"); err != nil {
+		return nil, err
+	}
+
+	body, err := p.parseCompoundList()
+	if err != nil {
+		return nil, err
+	}
+
+	return body, p.expectWord("done")
+}
+
+// isWord reports whether the current token is the unquoted word text.
+func (p *Parser) isWord(text string) bool {
+	return p.token.Kind == TOKEN_WORD && p.token.Word.IsUnquoted(text)
+}
+
+// parseCondCommand parses a [[ ... ]] command. The words inside it are
+// operands and operators of the expression rather than commands, so '<',
+// '>', '&&' and '||' take on a different meaning.
+func (p *Parser) parseCondCommand() (*CondCommand, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	expr, err := p.parseCondOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isWord("]]") {
+		return nil, p.condError()
+	}
+
+	cmd := &CondCommand{Expr: expr}
+	return cmd, p.advance()
+}
+
+func (p *Parser) condError() error {
+	if p.token.Kind == TOKEN_EOF {
+		return p.unexpectedToken()
+	}
+	return fmt.Errorf("syntax error in conditional expression near '%s'", p.token.Text)
+}
+
+func (p *Parser) parseCondOr() (CondExpr, error) {
+	left, err := p.parseCondAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOperator("||") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseCondAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &CondLogical{Op: "||", Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *Parser) parseCondAnd() (CondExpr, error) {
+	left, err := p.parseCondTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOperator("&&") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseCondTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = &CondLogical{Op: "&&", Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *Parser) parseCondTerm() (CondExpr, error) {
+	switch {
+	case p.isWord("!"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		expr, err := p.parseCondTerm()
+		if err != nil {
+			return nil, err
+		}
+		return &CondNot{Expr: expr}, nil
+
+	case p.isOperator("("):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		expr, err := p.parseCondOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isOperator(")") {
+			return nil, p.condError()
+		}
+		return expr, p.advance()
+
+	case p.token.Kind != TOKEN_WORD || p.isWord("]]"):
+		return nil, p.condError()
+	}
+
+	word := p.token.Word
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	// An operator followed by ]] is a lone word, as in [[ -f ]]
+	if op, ok := condOperator(word, COND_UNARY_OPS); ok && p.token.Kind == TOKEN_WORD && !p.isWord("]]") {
+		operand := p.token.Word
+		return &CondUnary{Op: op, Operand: operand}, p.advance()
+	}
+
+	op, ok := "", false
+	switch p.token.Kind {
+	case TOKEN_WORD:
+		op, ok = condOperator(p.token.Word, COND_BINARY_OPS)
+	case TOKEN_OPERATOR:
+		op, ok = p.token.Text, p.isOperator("<") || p.isOperator(">")
+	}
+	if !ok {
+		return &CondUnary{Op: "-n", Operand: word}, nil
+	}
+
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.token.Kind != TOKEN_WORD {
+		return nil, p.condError()
+	}
+
+	right := p.token.Word
+	return &CondBinary{Op: op, Left: word, Right: right}, p.advance()
+}
+
+// condOperator returns the operator that word spells out if it is one of
+// ops and unquoted.
+func condOperator(word *Word, ops []string) (string, bool) {
+	for _, op := range ops {
+		if word.IsUnquoted(op) {
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *Parser) parseSimpleCommand() (*SimpleCommand, error) {
+	cmd := &SimpleCommand{}
+
+	for {
+		switch {
+		case p.token.Kind == TOKEN_WORD:
+			if len(cmd.Words) == 0 {
+				if assignment := parseAssignment(p.token.Word); assignment != nil {
+					cmd.Assignments = append(cmd.Assignments, assignment)
+					break
+				}
+			}
+			cmd.Words = append(cmd.Words, p.token.Word)
+
+		case p.token.Kind == TOKEN_OPERATOR && isRedirection(p.token.Text):
+			redirect, err := p.parseRedirect()
+			if err != nil {
+				return nil, err
+			}
+			cmd.Redirects = append(cmd.Redirects, redirect)
+
+		default:
+			if len(cmd.Assignments) == 0 && len(cmd.Words) == 0 && len(cmd.Redirects) == 0 {
+				if p.isOperator("|") {
+					return nil, fmt.Errorf("missing command before/after '|'")
+				}
+				return nil, p.unexpectedToken()
+			}
+			return cmd, nil
+		}
+
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseRedirect parses a redirection operator and its target. Without a
+// file descriptor number in front of the operator, operators starting with
+// '<' apply to stdin and the others to stdout.
+func (p *Parser) parseRedirect() (*Redirect, error) {
+	text := p.token.Text
+	op := strings.TrimLeft(text, "0123456789")
+
+	fd := 1
+	if number := text[:len(text)-len(op)]; number != "" {
+		n, err := strconv.Atoi(number)
+		if err != nil || n > MAX_FD {
+			return nil, fmt.Errorf("%s: bad file descriptor", number)
+		}
+		fd = n
+	} else if op[0] == '<' {
+		fd = 0
+	}
+
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.token.Kind != TOKEN_WORD {
+		return nil, fmt.Errorf("expected file name after '%s'", text)
+	}
+
+	redirect := &Redirect{Fd: fd, Op: op, Target: p.token.Word}
+	if op == "<<" || op == "<<-" {
+		// The lexer reads the body once it reaches the end of the line
+		p.lexer.hereDocs = append(p.lexer.hereDocs, redirect)
+	}
+	return redirect, nil
+}
+
+// parseAssignment returns the assignment described by word if it starts
+// with an unquoted NAME=, otherwise it returns nil.
+func parseAssignment(word *Word) *Assignment {
+	if len(word.Parts) == 0 {
+		return nil
+	}
+
+	first, ok := word.Parts[0].(*Literal)
+	if !ok || first.Quoted {
+		return nil
+	}
+
+	prefix := ASSIGNMENT_REGEX.FindString(first.Text)
+	if prefix == "" {
+		return nil
+	}
+
+	value := &Word{}
+	if rest := first.Text[len(prefix):]; rest != "" {
+		value.Parts = append(value.Parts, &Literal{Text: rest})
+	}
+	value.Parts = append(value.Parts, word.Parts[1:]...)
+
+	return &Assignment{Name: prefix[:len(prefix)-1], Value: value}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Flattened view of a SimpleCommand used to compare parser output
+
+type simpleCommandSummary struct {
+	Assignments []string
+	Words       []string
+	Redirects   []string
+}
+
+func summarizePipeline(pipeline *PipelineNode) []simpleCommandSummary {
+	var res []simpleCommandSummary
+	for _, node := range pipeline.Commands {
+		var summary simpleCommandSummary
+		cmd, ok := node.(*SimpleCommand)
+		if !ok {
+			res = append(res, summary)
+			continue
+		}
+		for _, assignment := range cmd.Assignments {
+			summary.Assignments = append(summary.Assignments, assignment.Name+"="+assignment.Value.Literal())
+		}
+		for _, word := range cmd.Words {
+			summary.Words = append(summary.Words, word.Literal())
+		}
+		for _, redirect := range cmd.Redirects {
+			summary.Redirects = append(summary.Redirects, strconv.Itoa(redirect.Fd)+redirect.Op+" "+redirect.Target.Literal())
+		}
+		res = append(res, summary)
+	}
+	return res
+}
+
+func TestParsePipeline(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected []simpleCommandSummary
+	}{
+		{
+			name:  "simple command",
+			input: "echo hello world",
+			expected: []simpleCommandSummary{
+				{Words: []string{"echo", "hello", "world"}},
+			},
+		},
+		{
+			name:  "pipeline",
+			input: "cat README.md | grep bitbash | wc -l",
+			expected: []simpleCommandSummary{
+				{Words: []string{"cat", "README.md"}},
+				{Words: []string{"grep", "bitbash"}},
+				{Words: []string{"wc", "-l"}},
+			},
+		},
+		{
+			name:  "pipe with standard error",
+			input: "make 2> /dev/null |& grep error",
+			expected: []simpleCommandSummary{
+				{Words: []string{"make"}, Redirects: []string{"2> /dev/null", "2>& 1"}},
+				{Words: []string{"grep", "error"}},
+			},
+		},
+		{
+			name:  "quoted pipe is an argument",
+			input: `echo "|" grep`,
+			expected: []simpleCommandSummary{
+				{Words: []string{"echo", "|", "grep"}},
+			},
+		},
+		{
+			name:  "redirections",
+			input: "cat < in.txt > out.txt 2>> err.txt",
+			expected: []simpleCommandSummary{
+				{Words: []string{"cat"}, Redirects: []string{"0< in.txt", "1> out.txt", "2>> err.txt"}},
+			},
+		},
+		{
+			name:  "file descriptor redirections",
+			input: "cmd 3<in.txt 2>&1 >&- <>rw.txt 12>out.txt &>all.txt",
+			expected: []simpleCommandSummary{
+				{Words: []string{"cmd"}, Redirects: []string{"3< in.txt", "2>& 1", "1>& -", "0<> rw.txt", "12> out.txt", "1&> all.txt"}},
+			},
+		},
+		{
+			name:  "assignments before command name",
+			input: `FOO=bar BAZ="a b" env A=1`,
+			expected: []simpleCommandSummary{
+				{Assignments: []string{"FOO=bar", "BAZ=a b"}, Words: []string{"env", "A=1"}},
+			},
+		},
+		{
+			name:  "quoted name is not an assignment",
+			input: `'FOO'=bar`,
+			expected: []simpleCommandSummary{
+				{Words: []string{"FOO=bar"}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			list, err := Parse(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(list.Items) != 1 || len(list.Items[0].Pipelines) != 1 {
+				t.Fatalf("expected a single pipeline, got %#v", list.Items)
+			}
+
+			res := summarizePipeline(list.Items[0].Pipelines[0])
+			if !reflect.DeepEqual(res, tc.expected) {
+				t.Fatalf("expected: %#v, got: %#v", tc.expected, res)
+			}
+		})
+	}
+}
+
+func TestParseList(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected [][]string
+	}{
+		{
+			name:     "sequential commands",
+			input:    "cd dir; ls",
+			expected: [][]string{{"cd dir"}, {"ls"}},
+		},
+		{
+			name:     "trailing semicolon",
+			input:    "echo hi ;",
+			expected: [][]string{{"echo hi"}},
+		},
+		{
+			name:     "conditional commands",
+			input:    "make && ./run || echo failed",
+			expected: [][]string{{"make", "&&", "./run", "||", "echo failed"}},
+		},
+		{
+			name:     "mixed lists",
+			input:    "true && echo a;false || echo b | cat",
+			expected: [][]string{{"true", "&&", "echo a"}, {"false", "||", "echo b | cat"}},
+		},
+		{
+			name:     "operators without spaces",
+			input:    "true&&echo a;false||echo b|cat",
+			expected: [][]string{{"true", "&&", "echo a"}, {"false", "||", "echo b | cat"}},
+		},
+		{
+			name:     "newline separated commands",
+			input:    "echo a\n\necho b &&\n echo c\n",
+			expected: [][]string{{"echo a"}, {"echo b", "&&", "echo c"}},
+		},
+		{
+			name:     "quoted semicolon",
+			input:    `echo "a;b" c\;d`,
+			expected: [][]string{{"echo a;b c;d"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			list, err := Parse(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			var res [][]string
+			for _, item := range list.Items {
+				var andOr []string
+				for i, pipeline := range item.Pipelines {
+					if i > 0 {
+						andOr = append(andOr, item.Operators[i-1])
+					}
+
+					var commands []string
+					for _, cmd := range summarizePipeline(pipeline) {
+						commands = append(commands, strings.Join(cmd.Words, " "))
+					}
+					andOr = append(andOr, strings.Join(commands, " | "))
+				}
+				res = append(res, andOr)
+			}
+
+			if !reflect.DeepEqual(res, tc.expected) {
+				t.Fatalf("expected: %#v, got: %#v", tc.expected, res)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+	}{
+		{name: "leading pipe", input: "| cat"},
+		{name: "trailing pipe", input: "echo |"},
+		{name: "double pipe", input: "echo | | cat"},
+		{name: "missing redirection target", input: "echo >"},
+		{name: "redirection before pipe", input: "echo > | cat"},
+		{name: "unclosed quote", input: `echo "hello`},
+		{name: "leading semicolon", input: "; echo"},
+		{name: "double semicolon", input: "echo a ; ; echo b"},
+		{name: "missing command after and", input: "echo a &&"},
+		{name: "missing command before or", input: "|| echo b"},
+		{name: "file descriptor out of range", input: "echo 99999999999999999999> out.txt"},
+		{name: "unterminated here-document", input: "cat <<EOF\nbody"},
+		{name: "missing here-document delimiter", input: "cat <<"},
+		{name: "if without then", input: "if true; fi"},
+		{name: "if without condition", input: "if then echo a; fi"},
+		{name: "if without body", input: "if true; then fi"},
+		{name: "if without fi", input: "if true; then echo a"},
+		{name: "else without if", input: "echo a; else echo b"},
+		{name: "word after fi", input: "if true; then echo a; fi echo"},
+		{name: "while without do", input: "while true; done"},
+		{name: "while without done", input: "while true; do echo a"},
+		{name: "until without condition", input: "until do echo a; done"},
+		{name: "for without name", input: "for; do echo a; done"},
+		{name: "for with invalid name", input: "for 1x in a; do echo a; done"},
+		{name: "for without do", input: "for x in a b; echo $x; done"},
+		{name: "done without loop", input: "echo a; done"},
+		{name: "arithmetic for with two expressions", input: "for ((i=0; i<3)); do echo $i; done"},
+		{name: "arithmetic for without do", input: "for ((;;)) echo a; done"},
+		{name: "select without name", input: "select; do echo a; done"},
+		{name: "select without done", input: "select x in a b; do echo $x"},
+		{name: "case without in", input: "case a; esac"},
+		{name: "case without esac", input: "case a in a) echo a;;"},
+		{name: "case item without parenthesis", input: "case a in a echo a;; esac"},
+		{name: "case item without pattern", input: "case a in ) echo a;; esac"},
+		{name: "esac without case", input: "echo a; esac"},
+		{name: "case terminator outside case", input: "echo a ;& echo b"},
+		{name: "brace group without closing brace", input: "{ echo a; "},
+		{name: "closing brace without group", input: "echo a; }"},
+		{name: "empty brace group", input: "{ }"},
+		{name: "function with simple command body", input: "f() echo a"},
+		{name: "function without body", input: "f()"},
+		{name: "function with quoted name", input: "'f'() { echo a; }"},
+		{name: "function keyword without name", input: "function { echo a; }"},
+		{name: "function with arguments in parentheses", input: "f(x) { echo a; }"},
+		{name: "double semicolon without spaces", input: "echo a;;"},
+		{name: "newline before redirection target", input: "echo >\nfile"},
+		{name: "unclosed command substitution", input: "echo $(echo a"},
+		{name: "unclosed process substitution", input: "cat <(echo a"},
+		{name: "unclosed backquote", input: "echo `echo a"},
+		{name: "unmatched parenthesis", input: "echo a )"},
+		{name: "unclosed arithmetic expansion", input: "echo $((1 + 2)"},
+		{name: "unclosed arithmetic command", input: "((1 + 2"},
+		{name: "word after arithmetic command", input: "((1)) echo"},
+		{name: "unclosed conditional", input: "[[ a == b"},
+		{name: "empty conditional", input: "[[ ]]"},
+		{name: "missing conditional operand", input: "[[ a == ]]"},
+		{name: "unclosed conditional group", input: "[[ ( a ]]"},
+		{name: "backslash at end of input", input: "echo a \\"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Parse(tc.input); err == nil {
+				t.Fatalf("expected error parsing %#v", tc.input)
+			}
+		})
+	}
+}
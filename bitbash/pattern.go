@@ -0,0 +1,478 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+type patternKind int
+
+const (
+	PATTERN_LITERAL patternKind = iota
+	PATTERN_ANY_CHAR
+	PATTERN_ANY_STRING
+	PATTERN_BRACKET
+	PATTERN_EXTGLOB
+)
+
+// patternNode is a single element of a compiled pattern. For extglob
+// groups Char is the operator in front of the parentheses and
+// Alternatives holds the patterns separated by '|'.
+type patternNode struct {
+	Kind         patternKind
+	Char         rune
+	Negate       bool
+	Members      []bracketMember
+	Alternatives [][]patternNode
+}
+
+// bracketMember is a single character, a range such as a-z or a
+// character class such as [:alpha:] inside a bracket expression.
+type bracketMember struct {
+	Low, High rune
+	Class     string
+}
+
+// Pattern is a compiled shell pattern supporting '*', '?', bracket
+// expressions and backslash escapes, along with the ksh style ?(...),
+// *(...), +(...), @(...) and !(...) groups when extglob is on.
+type Pattern struct {
+	nodes []patternNode
+}
+
+func CompilePattern(pattern string, extglob bool) *Pattern {
+	return &Pattern{nodes: compileNodes([]rune(pattern), extglob)}
+}
+
+func compileNodes(runes []rune, extglob bool) []patternNode {
+	var nodes []patternNode
+
+	for i := 0; i < len(runes); i++ {
+		if extglob && i+1 < len(runes) && runes[i+1] == '(' && strings.ContainsRune("?*+@!", runes[i]) {
+			if node, end, ok := compileExtglob(runes, i); ok {
+				nodes = append(nodes, node)
+				i = end
+				continue
+			}
+		}
+
+		switch c := runes[i]; c {
+		case '*':
+			// Consecutive stars match the same strings as a single star
+			if n := len(nodes); n == 0 || nodes[n-1].Kind != PATTERN_ANY_STRING {
+				nodes = append(nodes, patternNode{Kind: PATTERN_ANY_STRING})
+			}
+		case '?':
+			nodes = append(nodes, patternNode{Kind: PATTERN_ANY_CHAR})
+		case '[':
+			node, end, ok := compileBracket(runes, i)
+			if !ok {
+				nodes = append(nodes, patternNode{Kind: PATTERN_LITERAL, Char: c})
+				continue
+			}
+			nodes = append(nodes, node)
+			i = end
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+			}
+			nodes = append(nodes, patternNode{Kind: PATTERN_LITERAL, Char: runes[i]})
+		default:
+			nodes = append(nodes, patternNode{Kind: PATTERN_LITERAL, Char: c})
+		}
+	}
+
+	return nodes
+}
+
+// compileExtglob compiles the extglob group starting at runes[start] and
+// returns the index of its closing ')'. ok is false when the group is
+// never closed, in which case it is matched like any other text.
+func compileExtglob(runes []rune, start int) (node patternNode, end int, ok bool) {
+	node.Kind = PATTERN_EXTGLOB
+	node.Char = runes[start]
+
+	depth := 0
+	altStart := start + 2
+
+	for i := altStart; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+				continue
+			}
+			node.Alternatives = append(node.Alternatives, compileNodes(runes[altStart:i], true))
+			return node, i, true
+		case '|':
+			if depth == 0 {
+				node.Alternatives = append(node.Alternatives, compileNodes(runes[altStart:i], true))
+				altStart = i + 1
+			}
+		}
+	}
+
+	return node, 0, false
+}
+
+// compileBracket compiles the bracket expression starting at runes[start]
+// and returns the index of its closing ']'. ok is false when the bracket
+// is never closed, in which case '[' is matched literally.
+func compileBracket(runes []rune, start int) (node patternNode, end int, ok bool) {
+	node.Kind = PATTERN_BRACKET
+	i := start + 1
+
+	if i < len(runes) && (runes[i] == '!' || runes[i] == '^') {
+		node.Negate = true
+		i++
+	}
+
+	for first := true; i < len(runes); first = false {
+		c := runes[i]
+
+		// A ']' right after the opening bracket is a member, not the end
+		if c == ']' && !first {
+			return node, i, true
+		}
+
+		if c == '[' && i+1 < len(runes) && runes[i+1] == ':' {
+			rest := string(runes[i+2:])
+			if end := strings.Index(rest, ":]"); end != -1 {
+				node.Members = append(node.Members, bracketMember{Class: rest[:end]})
+				i += 2 + len([]rune(rest[:end])) + 2
+				continue
+			}
+		}
+
+		if c == '\\' && i+1 < len(runes) {
+			i++
+			c = runes[i]
+		}
+
+		member := bracketMember{Low: c, High: c}
+		if i+2 < len(runes) && runes[i+1] == '-' && runes[i+2] != ']' {
+			member.High = runes[i+2]
+			i += 2
+		}
+		node.Members = append(node.Members, member)
+		i++
+	}
+
+	return node, 0, false
+}
+
+func (m bracketMember) matches(c rune) bool {
+	switch m.Class {
+	case "":
+		return m.Low <= c && c <= m.High
+	case "alnum":
+		return unicode.IsLetter(c) || unicode.IsDigit(c)
+	case "alpha":
+		return unicode.IsLetter(c)
+	case "blank":
+		return c == ' ' || c == '\t'
+	case "cntrl":
+		return unicode.IsControl(c)
+	case "digit":
+		return '0' <= c && c <= '9'
+	case "graph":
+		return unicode.IsGraphic(c) && !unicode.IsSpace(c)
+	case "lower":
+		return unicode.IsLower(c)
+	case "print":
+		return unicode.IsPrint(c)
+	case "punct":
+		return unicode.IsPunct(c) || unicode.IsSymbol(c)
+	case "space":
+		return unicode.IsSpace(c)
+	case "upper":
+		return unicode.IsUpper(c)
+	case "xdigit":
+		return strings.ContainsRune("0123456789abcdefABCDEF", c)
+	}
+	return false
+}
+
+func (n patternNode) matches(c rune) bool {
+	switch n.Kind {
+	case PATTERN_LITERAL:
+		return n.Char == c
+	case PATTERN_ANY_CHAR:
+		return true
+	case PATTERN_BRACKET:
+		for _, member := range n.Members {
+			if member.matches(c) {
+				return !n.Negate
+			}
+		}
+		return n.Negate
+	}
+	return false
+}
+
+func (p *Pattern) Match(s string) bool {
+	runes := []rune(s)
+	return newMatcher(runes).matchNodes(p.nodes, 0, len(runes))
+}
+
+// matcher matches nodes against parts of s given by their start and end
+// offsets. An extglob group can split the string in many ways and each
+// one is tried again for every way the nodes around it match, so the
+// results for the groups are remembered.
+type matcher struct {
+	s        []rune
+	rest     map[matchKey]bool
+	groups   map[matchKey]bool
+	repeated map[matchKey]bool
+}
+
+// matchKey identifies the nodes starting at node, up to the end of the
+// pattern or alternative holding it, matched against s[start:end].
+type matchKey struct {
+	node       *patternNode
+	start, end int
+}
+
+func newMatcher(s []rune) *matcher {
+	return &matcher{
+		s:        s,
+		rest:     make(map[matchKey]bool),
+		groups:   make(map[matchKey]bool),
+		repeated: make(map[matchKey]bool),
+	}
+}
+
+// matchNodes reports whether s[start:end] as a whole matches nodes. After
+// a mismatch it only goes back to the last '*' and lets it match one more
+// character, since the earlier stars can't do any better, which keeps the
+// matching linear in the number of stars.
+func (m *matcher) matchNodes(nodes []patternNode, start, end int) bool {
+	n, i := 0, start
+	star, starOffset := -1, 0
+
+	for n < len(nodes) || i < end {
+		if n < len(nodes) {
+			node := nodes[n]
+
+			switch {
+			case node.Kind == PATTERN_EXTGLOB:
+				if m.matchGroupThen(nodes[n:], i, end) {
+					return true
+				}
+			case node.Kind == PATTERN_ANY_STRING:
+				star, starOffset = n, i
+				n++
+				continue
+			case i < end && node.matches(m.s[i]):
+				n++
+				i++
+				continue
+			}
+		}
+
+		if star == -1 || starOffset == end {
+			return false
+		}
+		starOffset++
+		n, i = star+1, starOffset
+	}
+
+	return true
+}
+
+// matchGroupThen reports whether s[start:end] matches nodes, which start
+// with an extglob group, by matching the rest of the nodes after every
+// string the group can match.
+func (m *matcher) matchGroupThen(nodes []patternNode, start, end int) bool {
+	key := matchKey{&nodes[0], start, end}
+	if matched, ok := m.rest[key]; ok {
+		return matched
+	}
+
+	matched := false
+	for i := end; i >= start && !matched; i-- {
+		matched = m.matchesGroup(&nodes[0], start, i) && m.matchNodes(nodes[1:], i, end)
+	}
+
+	m.rest[key] = matched
+	return matched
+}
+
+// matchesGroup reports whether s[start:end] as a whole matches an extglob
+// group: zero or one (?), zero or more (*), one or more (+) or exactly one
+// (@) of the alternatives, or anything except one of them (!).
+func (m *matcher) matchesGroup(group *patternNode, start, end int) bool {
+	key := matchKey{group, start, end}
+	if matched, ok := m.groups[key]; ok {
+		return matched
+	}
+
+	matched := false
+	switch group.Char {
+	case '?':
+		matched = start == end || m.matchAlternatives(group, start, end)
+	case '*':
+		matched = start == end || m.matchRepeated(group, start, end)
+	case '+':
+		matched = m.matchRepeated(group, start, end)
+	case '@':
+		matched = m.matchAlternatives(group, start, end)
+	case '!':
+		matched = !m.matchAlternatives(group, start, end)
+	}
+
+	m.groups[key] = matched
+	return matched
+}
+
+func (m *matcher) matchAlternatives(group *patternNode, start, end int) bool {
+	for _, nodes := range group.Alternatives {
+		if m.matchNodes(nodes, start, end) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRepeated reports whether s[start:end] is made of one or more
+// consecutive matches of the alternatives of group.
+func (m *matcher) matchRepeated(group *patternNode, start, end int) bool {
+	key := matchKey{group, start, end}
+	if matched, ok := m.repeated[key]; ok {
+		return matched
+	}
+
+	matched := m.matchAlternatives(group, start, end)
+	for i := start + 1; i < end && !matched; i++ {
+		matched = m.matchAlternatives(group, start, i) && m.matchRepeated(group, i, end)
+	}
+
+	m.repeated[key] = matched
+	return matched
+}
+
+// MatchPattern reports whether s matches the shell pattern.
+func MatchPattern(pattern, s string, extglob bool) bool {
+	return CompilePattern(pattern, extglob).Match(s)
+}
+
+// EscapePattern escapes the characters of s that have a special meaning
+// in a pattern so that it only matches itself.
+func EscapePattern(s string) string {
+	var sb strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune(`*?[]\`, c) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+// unescapePattern removes the backslashes added by EscapePattern.
+func unescapePattern(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// TrimPattern removes the shortest ("#") or longest ("##") prefix, or the
+// shortest ("%") or longest ("%%") suffix of s that matches pattern.
+func TrimPattern(s string, pattern *Pattern, op string) string {
+	nodes := pattern.nodes
+	runes := []rune(s)
+	n := len(runes)
+	m := newMatcher(runes)
+
+	switch op {
+	case "#":
+		for i := 0; i <= n; i++ {
+			if m.matchNodes(nodes, 0, i) {
+				return string(runes[i:])
+			}
+		}
+	case "##":
+		for i := n; i >= 0; i-- {
+			if m.matchNodes(nodes, 0, i) {
+				return string(runes[i:])
+			}
+		}
+	case "%":
+		for i := n; i >= 0; i-- {
+			if m.matchNodes(nodes, i, n) {
+				return string(runes[:i])
+			}
+		}
+	case "%%":
+		for i := 0; i <= n; i++ {
+			if m.matchNodes(nodes, i, n) {
+				return string(runes[:i])
+			}
+		}
+	}
+
+	return s
+}
+
+// ReplacePattern replaces the longest match of pattern in s with
+// replacement. "/" replaces the first match, "//" every match, "/#" a
+// match at the start of s and "/%" a match at the end of s.
+func ReplacePattern(s string, pattern *Pattern, replacement, op string) string {
+	nodes := pattern.nodes
+	runes := []rune(s)
+	n := len(runes)
+	m := newMatcher(runes)
+
+	switch op {
+	case "/#":
+		for i := n; i >= 0; i-- {
+			if m.matchNodes(nodes, 0, i) {
+				return replacement + string(runes[i:])
+			}
+		}
+		return s
+	case "/%":
+		for i := 0; i <= n; i++ {
+			if m.matchNodes(nodes, i, n) {
+				return string(runes[:i]) + replacement
+			}
+		}
+		return s
+	}
+
+	var sb strings.Builder
+	for i := 0; i < n; {
+		end := -1
+		for j := n; j > i; j-- {
+			if m.matchNodes(nodes, i, j) {
+				end = j
+				break
+			}
+		}
+
+		if end == -1 {
+			sb.WriteRune(runes[i])
+			i++
+			continue
+		}
+
+		sb.WriteString(replacement)
+		i = end
+
+		if op == "/" {
+			sb.WriteString(string(runes[i:]))
+			break
+		}
+	}
+
+	return sb.String()
+}
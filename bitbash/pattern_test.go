@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	testCases := []struct {
+		pattern  string
+		s        string
+		expected bool
+	}{
+		{pattern: "abc", s: "abc", expected: true},
+		{pattern: "abc", s: "abd", expected: false},
+		{pattern: "*", s: "", expected: true},
+		{pattern: "*.go", s: "main.go", expected: true},
+		{pattern: "*.go", s: "main.gox", expected: false},
+		{pattern: "a*b*c", s: "aXXbYYc", expected: true},
+		{pattern: "a*/c", s: "ab/c", expected: true},
+		{pattern: "?", s: "é", expected: true},
+		{pattern: "??", s: "a", expected: false},
+		{pattern: "[abc]x", s: "bx", expected: true},
+		{pattern: "[a-c]", s: "d", expected: false},
+		{pattern: "[!a-c]", s: "d", expected: true},
+		{pattern: "[^a-c]", s: "a", expected: false},
+		{pattern: "[]]", s: "]", expected: true},
+		{pattern: "[a-]", s: "-", expected: true},
+		{pattern: "[[:digit:]][[:upper:]]", s: "7Q", expected: true},
+		{pattern: "[[:alpha:]]", s: "7", expected: false},
+		{pattern: "[ab", s: "[ab", expected: true},
+		{pattern: `\*`, s: "*", expected: true},
+		{pattern: `\*`, s: "a", expected: false},
+		{pattern: `[\]]`, s: "]", expected: true},
+		{pattern: "*ab", s: "aab", expected: true},
+		{pattern: "a*bc", s: "abcbc", expected: true},
+		{pattern: "*a?", s: "ab", expected: true},
+		{pattern: "*a*b", s: "aXbXa", expected: false},
+		{pattern: "*a*a*a*a*a*a*a*a*a*a*a*a*c", s: strings.Repeat("a", 200), expected: false},
+		{pattern: "*a*a*a*a*a*a*a*a*a*a*a*a*c", s: strings.Repeat("a", 200) + "c", expected: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pattern+" "+tc.s, func(t *testing.T) {
+			if got := MatchPattern(tc.pattern, tc.s, false); got != tc.expected {
+				t.Fatalf("expected: %v, got: %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestMatchExtglob(t *testing.T) {
+	testCases := []struct {
+		pattern  string
+		s        string
+		expected bool
+	}{
+		{pattern: "@(foo|bar).txt", s: "bar.txt", expected: true},
+		{pattern: "@(foo|bar).txt", s: "foobar.txt", expected: false},
+		{pattern: "?(foo)bar", s: "bar", expected: true},
+		{pattern: "?(foo)bar", s: "foofoobar", expected: false},
+		{pattern: "*(ab)c", s: "c", expected: true},
+		{pattern: "*(ab)c", s: "ababc", expected: true},
+		{pattern: "*(ab)c", s: "abac", expected: false},
+		{pattern: "+(ab|c)", s: "abcab", expected: true},
+		{pattern: "+(ab)", s: "", expected: false},
+		{pattern: "!(*.txt)", s: "main.go", expected: true},
+		{pattern: "!(*.txt)", s: "notes.txt", expected: false},
+		{pattern: "a!(b)c", s: "abc", expected: false},
+		{pattern: "a!(b)c", s: "axc", expected: true},
+		{pattern: "@(a|+([0-9]))x", s: "123x", expected: true},
+		{pattern: `@(a\|b)`, s: "a|b", expected: true},
+		{pattern: "@(a|b", s: "@(a|b", expected: true},
+		{pattern: "+(a|aa)", s: strings.Repeat("a", 60), expected: true},
+		{pattern: "+(a|aa)b", s: strings.Repeat("a", 60), expected: false},
+		{pattern: "*(a|aa)*(a|aa)c", s: strings.Repeat("a", 60) + "b", expected: false},
+		{pattern: "+(+(a|aa)|b)x", s: strings.Repeat("a", 40) + "b", expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pattern+" "+tc.s, func(t *testing.T) {
+			if got := MatchPattern(tc.pattern, tc.s, true); got != tc.expected {
+				t.Fatalf("expected: %v, got: %v", tc.expected, got)
+			}
+		})
+	}
+
+	if MatchPattern("@(a|b)", "a", false) {
+		t.Fatalf("expected extglob group to be literal when extglob is off")
+	}
+}
@@ -1,77 +1,319 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 )
 
+// Stdio is the set of files commands inherit from their parent, such as
+// the terminal or the pipe that captures the output of a command
+// substitution.
+type Stdio struct {
+	In  *os.File
+	Out *os.File
+	Err *os.File
+	// File descriptors 3 and up, set up by the redirections of a compound
+	// command for the commands inside it
+	Extra []*os.File
+}
+
+func NewStdio() *Stdio {
+	return &Stdio{In: os.Stdin, Out: os.Stdout, Err: os.Stderr}
+}
+
 type Command struct {
 	Name      string
 	Args      []string
 	IsBuiltin bool
+	Function  *FunctionDef
+	Compound  CompoundCommand
 	in        *os.File
 	out       *os.File
 	err       *os.File
+	extra     []*os.File // File descriptors 3 and up, nil when closed
+	procSubs  []*ProcSub
 	initErr   error
+	parent    *Stdio
+	shell     *Config // The shell state the command is expanded and run in
+	owned     []*os.File
+	Env       []string
+	TempVars  [][2]string
+	Status    int
 }
 
-func (cmd *Command) Init(tokens []string) {
-	for i := 0; i < len(tokens); {
-		token := tokens[i]
+func (cmd *Command) Init(node CommandNode, cfg *Config) {
+	// Process substitutions started while expanding the command's words and
+	// redirections last until it finishes
+	cfg.ProcSubs = nil
+	defer func() {
+		cmd.procSubs = cfg.ProcSubs
+		cfg.ProcSubs = nil
+	}()
+
+	switch n := node.(type) {
+	case *SimpleCommand:
+		cmd.initSimple(n, cfg)
+	case *Redirected:
+		cmd.Compound = n.Command
+		cmd.initRedirects(n.Redirects, cfg)
+	case CompoundCommand:
+		cmd.Compound = n
+	}
+}
 
-		if _, ok := REDIRECTION_OPS[token]; ok {
-			cmd.SetRedirect(token, tokens[i+1])
+func (cmd *Command) initSimple(node *SimpleCommand, cfg *Config) {
+	cfg.SubstStatus = 0
 
-			i += 2
-			continue
+	words := node.Words
+	if len(words) > 0 && slices.ContainsFunc(DECLARATION_BUILTINS, words[0].IsUnquoted) {
+		words = slices.Clone(words)
+		for i := 1; i < len(words); i++ {
+			words[i] = cfg.TildeExpandDeclaration(words[i])
 		}
+	}
 
+	fields, err := cfg.ExpandWords(words, cmd.parent)
+	if err != nil {
+		cmd.initErr = err
+		return
+	}
+
+	// The paths of process substitutions refer to the shell's file
+	// descriptors, so programs get the pipes under the same numbers
+	for _, sub := range cfg.ProcSubs {
+		cmd.SetFile(int(sub.File.Fd()), sub.File)
+	}
+
+	for _, token := range fields {
 		if cmd.Name == "" {
 			cmd.Name = token
-			_, ok := BUILTIN_CMDS[token]
-			cmd.IsBuiltin = ok
+			// Functions are found before builtins and programs
+			if fn, ok := cfg.Functions[token]; ok {
+				cmd.Function = fn
+			} else {
+				_, ok := BUILTIN_CMDS[token]
+				cmd.IsBuiltin = ok
+			}
 
 		} else {
 			cmd.Args = append(cmd.Args, token)
 		}
+	}
+
+	if cmd.initRedirects(node.Redirects, cfg); cmd.initErr != nil {
+		return
+	}
+
+	// Without a command name the assignments set shell variables and
+	// the status is that of the last command substitution
+	if cmd.Name == "" {
+		for _, assignment := range node.Assignments {
+			value, err := cfg.ExpandWord(cfg.TildeExpand(assignment.Value, true), cmd.parent)
+			if err == nil {
+				err = cfg.SetVar(assignment.Name, value)
+			}
+			if err != nil {
+				cmd.initErr = err
+				return
+			}
+		}
+		cmd.Status = cfg.SubstStatus
+		return
+	}
+
+	// With a command name the assignments only apply while the command
+	// runs, so they are set just long enough to build its environment
+	var restores []func()
+	defer func() {
+		for i := len(restores) - 1; i >= 0; i-- {
+			restores[i]()
+		}
+	}()
+
+	for _, assignment := range node.Assignments {
+		value, err := cfg.ExpandWord(cfg.TildeExpand(assignment.Value, true), cmd.parent)
+		if err != nil {
+			cmd.initErr = err
+			return
+		}
+
+		restore, err := cfg.SetTempVar(assignment.Name, value)
+		if err != nil {
+			fmt.Fprintf(cmd.err, "%s\n", err)
+			continue
+		}
+
+		restores = append(restores, restore)
+		cmd.TempVars = append(cmd.TempVars, [2]string{assignment.Name, value})
+	}
 
-		i++
+	if !cmd.IsBuiltin && cmd.Function == nil {
+		cmd.Env = cfg.Environ()
 	}
 }
 
-func (cmd *Command) SetRedirect(operator, fileName string) {
+// initRedirects expands and applies redirects from left to right,
+// stopping at the first one that fails.
+func (cmd *Command) initRedirects(redirects []*Redirect, cfg *Config) {
+	for _, redirect := range redirects {
+		target, err := cfg.ExpandRedirect(redirect, cmd.parent)
+		if err != nil {
+			cmd.initErr = err
+			return
+		}
+		if cmd.SetRedirect(redirect.Fd, redirect.Op, target, cfg); cmd.initErr != nil {
+			return
+		}
+	}
+}
+
+// SetRedirect points the file descriptor fd at target. For '<&' and '>&'
+// target is the number of the file descriptor to duplicate or '-' to close
+// fd, for here-documents and here-strings it is the text to read and
+// otherwise it is the name of the file to open. With the noclobber option
+// '>' and '&>' refuse to overwrite an existing regular file.
+func (cmd *Command) SetRedirect(fd int, operator, target string, cfg *Config) {
+	if strings.HasPrefix(operator, "<<") {
+		file, err := hereDocFile(target)
+		if err != nil {
+			cmd.initErr = err
+			return
+		}
+		cmd.owned = append(cmd.owned, file)
+		cmd.SetFile(fd, file)
+		return
+	}
+
+	if operator == "<&" || operator == ">&" {
+		if target == "-" {
+			cmd.SetFile(fd, nil)
+			return
+		}
+
+		n, err := strconv.Atoi(target)
+		if err != nil || n < 0 {
+			cmd.initErr = fmt.Errorf("%s: ambiguous redirect", target)
+			return
+		}
+
+		file := cmd.File(n)
+		if file == nil {
+			cmd.initErr = fmt.Errorf("%d: bad file descriptor", n)
+			return
+		}
+		cmd.SetFile(fd, file)
+		return
+	}
+
 	flags := REDIRECTION_OPS[operator]
+	if cfg.Options["noclobber"] && (operator == ">" || operator == "&>") {
+		// Files that are not regular, such as /dev/null, can still be
+		// written to. O_EXCL fails if the file is created in the meantime
+		info, err := os.Stat(cfg.ResolvePath(target))
+		if err == nil && info.Mode().IsRegular() {
+			cmd.initErr = fmt.Errorf("%s: cannot overwrite existing file", target)
+			return
+		}
+		if err != nil {
+			flags |= os.O_EXCL
+		}
+	}
 
-	file, err := os.OpenFile(fileName, flags, 0o666)
+	file, err := os.OpenFile(cfg.ResolvePath(target), flags, 0o666)
 	if err != nil {
+		// Report the file by the name it was given
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			pathErr.Path = target
+		}
 		cmd.initErr = err
 		return
 	}
+	cmd.owned = append(cmd.owned, file)
+
+	cmd.SetFile(fd, file)
+	if strings.HasPrefix(operator, "&>") {
+		cmd.SetFile(2, file)
+	}
+}
+
+// hereDocFile returns a file to read text from. Like in bash it is a
+// temporary file, removed straight away so that it disappears once closed.
+func hereDocFile(text string) (*os.File, error) {
+	file, err := os.CreateTemp("", "bitbash-heredoc-")
+	if err != nil {
+		return nil, err
+	}
+	os.Remove(file.Name())
+
+	if _, err := file.WriteString(text); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// File returns the file open as the file descriptor fd, or nil if fd is
+// closed.
+func (cmd *Command) File(fd int) *os.File {
+	switch fd {
+	case 0:
+		return cmd.in
+	case 1:
+		return cmd.out
+	case 2:
+		return cmd.err
+	}
+
+	if fd-3 < len(cmd.extra) {
+		return cmd.extra[fd-3]
+	}
+	return nil
+}
 
-	switch operator {
-	case "<":
+// SetFile makes file the file descriptor fd, a nil file closes it.
+func (cmd *Command) SetFile(fd int, file *os.File) {
+	switch fd {
+	case 0:
 		cmd.in = file
-	case ">", "1>", "1>>", ">>":
-		cmd.out = file
-	case "2>", "2>>":
-		cmd.err = file
-	case "&>", "&>>":
+	case 1:
 		cmd.out = file
+	case 2:
 		cmd.err = file
+	default:
+		for len(cmd.extra) <= fd-3 {
+			cmd.extra = append(cmd.extra, nil)
+		}
+		cmd.extra[fd-3] = file
 	}
 }
 
+// ClosePipes closes the pipes and files that were opened for the command,
+// leaving the files inherited from its parent open, and waits for its
+// process substitutions to finish.
 func (cmd *Command) ClosePipes() {
-	if cmd.in != os.Stdin {
-		cmd.in.Close()
+	for _, file := range cmd.owned {
+		file.Close()
 	}
-	if cmd.out != os.Stdout {
-		cmd.out.Close()
-	}
-	if cmd.err != os.Stderr {
-		cmd.err.Close()
+
+	for _, sub := range cmd.procSubs {
+		sub.File.Close()
+		<-sub.Done
 	}
 }
 
@@ -80,33 +322,134 @@ func (cmd *Command) Run(wg *sync.WaitGroup, cfg *Config) {
 	defer cmd.ClosePipes()
 
 	if cmd.initErr != nil {
-		fmt.Fprintf(os.Stderr, "%s\n", cmd.initErr)
+		fmt.Fprintf(cmd.parent.Err, "%s\n", cmd.initErr)
+		cmd.Status = 1
 		return
 	}
 
-	if cmd.IsBuiltin {
-		BUILTIN_CMDS[cmd.Name].Handler(cmd, cfg)
-	} else {
-		cmd.runExec()
+	if cmd.Compound != nil {
+		cmd.Status = cmd.Compound.Execute(cfg, cmd.stdio())
+		return
 	}
-}
 
-func (cmd *Command) runExec() {
-	exec := exec.Command(cmd.Name, cmd.Args...)
-	if exec.Err != nil {
-		fmt.Fprintf(os.Stderr, "%s: command not found\r\n", cmd.Name)
+	if cmd.Name == "" {
 		return
 	}
 
-	exec.Stdin = cmd.in
-	exec.Stdout = cmd.out
-	exec.Stderr = cmd.err
+	switch {
+	case cmd.Function != nil:
+		cmd.runFunction(cfg)
+	case cmd.IsBuiltin:
+		cmd.runBuiltin(cfg)
+	default:
+		cmd.Status = cmd.runExec(cfg)
+	}
+}
 
-	if err := exec.Start(); err != nil {
-		return
+// stdio returns the files of the command for the commands run inside it.
+func (cmd *Command) stdio() *Stdio {
+	return &Stdio{In: cmd.in, Out: cmd.out, Err: cmd.err, Extra: cmd.extra}
+}
+
+func (cmd *Command) runBuiltin(cfg *Config) {
+	defer cmd.setTempVars(cfg)()
+
+	cmd.Status = BUILTIN_CMDS[cmd.Name].Handler(cmd, cfg)
+}
+
+func (cmd *Command) runFunction(cfg *Config) {
+	defer cmd.setTempVars(cfg)()
+
+	cmd.Status = cfg.CallFunction(cmd.Function, cmd.Args, cmd.stdio())
+}
+
+// setTempVars sets the assignments in front of a command that runs in the
+// shell itself and returns a function that undoes them.
+func (cmd *Command) setTempVars(cfg *Config) (restore func()) {
+	var restores []func()
+	for _, tempVar := range cmd.TempVars {
+		if restore, err := cfg.SetTempVar(tempVar[0], tempVar[1]); err == nil {
+			restores = append(restores, restore)
+		}
+	}
+
+	return func() {
+		for i := len(restores) - 1; i >= 0; i-- {
+			restores[i]()
+		}
+	}
+}
+
+// runExec runs an external program and returns its exit status. Following
+// bash, 127 means the program could not be found, 126 that it could not be
+// executed and 128+N that it was terminated by signal N.
+func (cmd *Command) runExec(cfg *Config) int {
+	// A PATH assignment in front of the command changes where it is
+	// looked for, otherwise the shell's PATH is used even if not exported
+	pathVar, _ := cfg.GetVar("PATH")
+	for _, tempVar := range cmd.TempVars {
+		if tempVar[0] == "PATH" {
+			pathVar = tempVar[1]
+		}
+	}
+
+	path, err := cfg.lookPath(cmd.Name, pathVar)
+	if err != nil {
+		fmt.Fprintf(cmd.err, "%s: command not found\r\n", cmd.Name)
+		return 127
+	}
+
+	// Files[i] becomes file descriptor i of the program. Unlike with
+	// exec.Cmd, which opens /dev/null in their place, nil files are closed
+	files := append([]*os.File{cmd.in, cmd.out, cmd.err}, cmd.extra...)
+	process, err := os.StartProcess(path, append([]string{cmd.Name}, cmd.Args...), &os.ProcAttr{
+		Dir:   cfg.CurrentDirectory,
+		Env:   cmd.Env,
+		Files: files,
+	})
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(cmd.err, "%s: No such file or directory\n", cmd.Name)
+			return 127
+		}
+		fmt.Fprintf(cmd.err, "%s: Permission denied\n", cmd.Name)
+		return 126
+	}
+
+	state, err := process.Wait()
+	if err != nil {
+		fmt.Fprintf(cmd.err, "%s: %s\n", cmd.Name, err)
+		return 1
+	}
+
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return state.ExitCode()
+}
+
+// lookPath searches for an executable named file in the directories
+// listed by pathVar, separated by ':'. Names containing a slash are
+// returned unchanged. Relative paths are relative to the working directory
+// of the shell.
+func (cfg *Config) lookPath(file, pathVar string) (string, error) {
+	if strings.Contains(file, "/") {
+		return file, nil
+	}
+
+	for dir := range strings.SplitSeq(pathVar, ":") {
+		if dir == "" {
+			dir = "."
+		}
+
+		path := filepath.Join(dir, file)
+		info, err := os.Stat(cfg.ResolvePath(path))
+		if err == nil && !info.IsDir() && info.Mode()&0111 != 0 {
+			return path, nil
+		}
 	}
 
-	exec.Wait()
+	return "", exec.ErrNotFound
 }
 
 type PipeLine struct {
@@ -114,50 +457,82 @@ type PipeLine struct {
 	Len      int
 }
 
-func NewPipeline(tokens []string) *PipeLine {
-	splitTokens := splitOnPipes(tokens)
-
+func NewPipeline(node *PipelineNode, cfg *Config, stdio *Stdio) *PipeLine {
 	pipeline := PipeLine{
-		Commands: make([]*Command, 0, len(splitTokens)),
-		Len:      len(splitTokens),
+		Commands: make([]*Command, 0, len(node.Commands)),
+		Len:      len(node.Commands),
 	}
 
-	for range len(splitTokens) {
-		pipeline.Commands = append(pipeline.Commands, &Command{})
+	for range len(node.Commands) {
+		cmd := &Command{parent: stdio, extra: slices.Clone(stdio.Extra), shell: cfg}
+
+		// The commands of a pipeline run at the same time, so each one gets
+		// a subshell of its own. A lone command runs in the shell itself
+		if len(node.Commands) > 1 {
+			cmd.shell = cfg.Subshell()
+		}
+
+		pipeline.Commands = append(pipeline.Commands, cmd)
 	}
 
-	pipeline.ConnectPipes()
+	pipeline.ConnectPipes(stdio)
 
-	for i := range pipeline.Len {
-		pipeline.Commands[i].Init(splitTokens[i])
+	for i, cmd := range pipeline.Commands {
+		cmd.Init(node.Commands[i], cmd.shell)
 	}
 
 	return &pipeline
 }
 
-func (pl *PipeLine) ConnectPipes() {
-	pl.Commands[0].in = os.Stdin
+func (pl *PipeLine) ConnectPipes(stdio *Stdio) {
+	pl.Commands[0].in = stdio.In
 
 	for i := 0; i < len(pl.Commands)-1; i++ {
 		r, w, _ := os.Pipe()
 
 		pl.Commands[i+1].in = r
+		pl.Commands[i+1].owned = append(pl.Commands[i+1].owned, r)
 		pl.Commands[i].out = w
-		pl.Commands[i].err = os.Stderr
+		pl.Commands[i].owned = append(pl.Commands[i].owned, w)
+		pl.Commands[i].err = stdio.Err
 
 	}
 
-	pl.Commands[len(pl.Commands)-1].out = os.Stdout
-	pl.Commands[len(pl.Commands)-1].err = os.Stderr
+	pl.Commands[len(pl.Commands)-1].out = stdio.Out
+	pl.Commands[len(pl.Commands)-1].err = stdio.Err
 }
 
-func (pl *PipeLine) Execute(cfg *Config) {
+// Execute runs every command in the pipeline concurrently and returns
+// the status of the last command once they have all finished, or with the
+// pipefail option the status of the last command that failed. The status
+// is also saved as the value of $? and the status of every command as
+// the PIPESTATUS array.
+func (pl *PipeLine) Execute(cfg *Config) int {
 	var wg sync.WaitGroup
 	wg.Add(pl.Len)
 
 	for _, cmd := range pl.Commands {
-		go cmd.Run(&wg, cfg)
+		go cmd.Run(&wg, cmd.shell)
 	}
 
 	wg.Wait()
+
+	status := 0
+	cfg.PipeStatus = make([]int, 0, pl.Len)
+
+	for _, cmd := range pl.Commands {
+		// exit only ends the subshell of a command in a pipeline
+		if cmd.shell != cfg && cmd.shell.Exiting {
+			cmd.Status = cmd.shell.ExitStatus
+		}
+
+		cfg.PipeStatus = append(cfg.PipeStatus, cmd.Status)
+
+		if cmd.Status != 0 || !cfg.Options["pipefail"] {
+			status = cmd.Status
+		}
+	}
+
+	cfg.LastStatus = status
+	return status
 }
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os/user"
+	"strings"
+)
+
+// TildeExpand returns word with its tilde prefix replaced by the directory
+// it names: ~ for HOME, ~user for the home directory of user, ~+ for PWD
+// and ~- for OLDPWD. A tilde prefix is an unquoted '~' at the start of the
+// word followed by the characters up to the first '/'. In the value of an
+// assignment a tilde prefix can also follow any unquoted ':' and it ends
+// at the next ':'.
+func (cfg *Config) TildeExpand(word *Word, assignment bool) *Word {
+	res := &Word{}
+
+	for i, part := range word.Parts {
+		lit, ok := part.(*Literal)
+		if !ok || lit.Quoted {
+			res.Parts = append(res.Parts, part)
+			continue
+		}
+
+		last := i == len(word.Parts)-1
+		res.Parts = append(res.Parts, cfg.tildeLiteral(lit.Text, i == 0, last, assignment)...)
+	}
+
+	return res
+}
+
+// TildeExpandDeclaration expands the tilde prefixes in the value of a
+// NAME=value argument of a builtin such as export as it would be in an
+// assignment. Other words are returned unchanged.
+func (cfg *Config) TildeExpandDeclaration(word *Word) *Word {
+	assignment := parseAssignment(word)
+	if assignment == nil {
+		return word
+	}
+
+	value := cfg.TildeExpand(assignment.Value, true)
+	return &Word{Parts: append([]WordPart{&Literal{Text: assignment.Name + "="}}, value.Parts...)}
+}
+
+// tildeLiteral expands the tilde prefixes in the text of an unquoted
+// literal. The directory replacing a prefix is quoted so that it is not
+// split or used as a pattern.
+func (cfg *Config) tildeLiteral(text string, first, last, assignment bool) []WordPart {
+	var parts []WordPart
+	var curr strings.Builder
+
+	flush := func() {
+		if curr.Len() > 0 {
+			parts = append(parts, &Literal{Text: curr.String()})
+			curr.Reset()
+		}
+	}
+
+	for i := 0; i < len(text); i++ {
+		isPrefix := text[i] == '~' && ((i == 0 && first) || (assignment && i > 0 && text[i-1] == ':'))
+		if !isPrefix {
+			curr.WriteByte(text[i])
+			continue
+		}
+
+		end := i + 1
+		for end < len(text) && text[end] != '/' && !(assignment && text[end] == ':') {
+			end++
+		}
+
+		// A prefix that runs into a quoted or expanded part of the word is
+		// not expanded
+		dir, ok := "", false
+		if end < len(text) || last {
+			dir, ok = cfg.tildeDirectory(text[i+1 : end])
+		}
+		if !ok {
+			curr.WriteByte(text[i])
+			continue
+		}
+
+		flush()
+		parts = append(parts, &Literal{Text: dir, Quoted: true})
+		i = end - 1
+	}
+
+	flush()
+	return parts
+}
+
+func (cfg *Config) tildeDirectory(prefix string) (string, bool) {
+	switch prefix {
+	case "":
+		if home, ok := cfg.GetVar("HOME"); ok {
+			return home, true
+		}
+		return cfg.HomeDirectory, cfg.HomeDirectory != ""
+	case "+":
+		return cfg.GetVar("PWD")
+	case "-":
+		return cfg.GetVar("OLDPWD")
+	}
+
+	usr, err := user.Lookup(prefix)
+	if err != nil {
+		return "", false
+	}
+	return usr.HomeDir, true
+}
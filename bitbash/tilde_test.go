@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os/user"
+	"testing"
+)
+
+func TestTildeExpansion(t *testing.T) {
+	usr, err := user.Current()
+	if err != nil {
+		t.Skipf("no current user: %s", err)
+	}
+
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "home", input: "echo ~", expected: "/home/me"},
+		{name: "home with path", input: "echo ~/src", expected: "/home/me/src"},
+		{name: "user", input: "echo ~" + usr.Username + "/bin", expected: usr.HomeDir + "/bin"},
+		{name: "unknown user", input: "echo ~nosuchuser/bin", expected: "~nosuchuser/bin"},
+		{name: "working directory", input: "echo ~+", expected: "/tmp"},
+		{name: "previous directory", input: "echo ~-", expected: "/"},
+		{name: "quoted", input: `echo "~" \~ '~'/x`, expected: "~ ~ ~/x"},
+		{name: "quoted prefix", input: `echo ~"/a"`, expected: "~/a"},
+		{name: "not at start", input: "echo a~ a:~/x", expected: "a~ a:~/x"},
+		{name: "not split or globbed", input: "HOME='/a  b/*'; echo ~", expected: "/a  b/*"},
+		{name: "assignment", input: "P=~/bin; echo $P", expected: "/home/me/bin"},
+		{name: "after colon in assignment", input: "P=/usr/bin:~/bin:~-; echo $P", expected: "/usr/bin:/home/me/bin:/"},
+		{name: "after brace expansion", input: "echo {~,~+}/x", expected: "/home/me/x /tmp/x"},
+		{name: "conditional", input: "[[ ~ == /home/me ]] && echo yes", expected: "yes"},
+		{name: "export", input: "export P=~/bin:~-; echo $P", expected: "/home/me/bin:/"},
+		{name: "readonly", input: "readonly P=~; echo $P", expected: "/home/me"},
+		{name: "local", input: "f() { local P=~/a; echo $P; }; f", expected: "/home/me/a"},
+		{name: "quoted export", input: "export 'P=~'; echo $P", expected: "~"},
+		{name: "export argument without assignment", input: "export ~ 2> /dev/null; echo $?", expected: "1"},
+		{name: "default value", input: "unset u; echo ${u:-~/x} \"${u-~}\"", expected: "/home/me/x /home/me"},
+		{name: "alternate value", input: "u=1; echo ${u:+~+}", expected: "/tmp"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Chdir("/")
+			cfg := newTestConfig()
+			runLine(t, cfg, "HOME=/home/me; cd /tmp")
+
+			if got := runLineOutput(t, cfg, tc.input); got != tc.expected {
+				t.Fatalf("expected: %#v, got: %#v", tc.expected, got)
+			}
+		})
+	}
+}
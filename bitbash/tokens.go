@@ -1,107 +1,758 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 )
 
-func Tokenize(input string) ([]string, error) {
-	var tokens []string
+type TokenKind int
+
+const (
+	TOKEN_EOF TokenKind = iota
+	TOKEN_WORD
+	TOKEN_OPERATOR
+	TOKEN_ARITH
+)
+
+type Token struct {
+	Kind TokenKind
+	Text string
+	Word *Word
+}
+
+// ErrIncomplete is returned when the input ends in the middle of a command
+// that continues on the next line, such as a here-document.
+var ErrIncomplete = errors.New("unexpected end of input")
+
+type Lexer struct {
+	input string
+	pos   int
+	// Here-documents whose body starts on the line after the next newline
+	hereDocs []*Redirect
+}
+
+func NewLexer(input string) *Lexer {
+	return &Lexer{input: input}
+}
+
+func Tokenize(input string) ([]Token, error) {
+	var tokens []Token
+	lexer := NewLexer(input)
+
+	for {
+		token, err := lexer.NextToken()
+		if err != nil {
+			return nil, err
+		}
+		if token.Kind == TOKEN_EOF {
+			return tokens, nil
+		}
+		tokens = append(tokens, token)
+	}
+}
+
+func (l *Lexer) NextToken() (Token, error) {
+	// An escaped newline between words is skipped along with the blanks
+	for l.pos < len(l.input) {
+		if isBlank(l.input[l.pos]) {
+			l.pos++
+		} else if strings.HasPrefix(l.input[l.pos:], "\\\n") {
+			l.pos += 2
+		} else {
+			break
+		}
+	}
+
+	// A comment runs from a '#' at the start of a word to the end of the line
+	if l.pos < len(l.input) && l.input[l.pos] == '#' {
+		for l.pos < len(l.input) && l.input[l.pos] != '\n' {
+			l.pos++
+		}
+	}
+
+	if l.pos == len(l.input) {
+		if len(l.hereDocs) > 0 {
+			return Token{}, missingDelimiter(l.hereDocs[0])
+		}
+		return Token{Kind: TOKEN_EOF}, nil
+	}
+
+	start := l.pos
+
+	if strings.HasPrefix(l.input[l.pos:], "((") {
+		l.pos += 2
+		expr, err := l.readArith()
+		if err != nil {
+			return Token{}, err
+		}
+		return Token{Kind: TOKEN_ARITH, Text: l.input[start:l.pos], Word: expr}, nil
+	}
+
+	// A '<' or '>' followed by '(' starts a process substitution rather
+	// than a redirection
+	if !l.atProcessSub() {
+		if op := l.readOperator(); op != "" {
+			if op == "\n" {
+				if err := l.readHereDocs(); err != nil {
+					return Token{}, err
+				}
+			}
+			return Token{Kind: TOKEN_OPERATOR, Text: op}, nil
+		}
+	}
+
+	word, err := l.readWord()
+	if err != nil {
+		return Token{}, err
+	}
+
+	return Token{Kind: TOKEN_WORD, Text: l.input[start:l.pos], Word: word}, nil
+}
+
+// readOperator reads the longest operator at the current position. A
+// redirection operator can be preceded by the number of the file
+// descriptor it applies to, as in 2>. It returns "" if there is no
+// operator.
+func (l *Lexer) readOperator() string {
+	digits := 0
+	for l.pos+digits < len(l.input) && isDigit(l.input[l.pos+digits]) {
+		digits++
+	}
+
+	rest := l.input[l.pos+digits:]
+	if digits > 0 && !strings.HasPrefix(rest, "<") && !strings.HasPrefix(rest, ">") {
+		return ""
+	}
+
+	for _, op := range OPERATORS {
+		if strings.HasPrefix(rest, op) {
+			op = l.input[l.pos : l.pos+digits+len(op)]
+			l.pos += len(op)
+			return op
+		}
+	}
+	return ""
+}
+
+// readHereDocs reads the bodies of the pending here-documents from the
+// lines that follow, each one ending at a line made of its delimiter.
+func (l *Lexer) readHereDocs() error {
+	for _, redirect := range l.hereDocs {
+		delimiter := redirect.Target.Literal()
+		var body strings.Builder
+
+		for {
+			if l.pos == len(l.input) {
+				return missingDelimiter(redirect)
+			}
+
+			line := l.input[l.pos:]
+			if end := strings.IndexByte(line, '\n'); end != -1 {
+				line = line[:end]
+				l.pos++
+			}
+			l.pos += len(line)
+
+			if redirect.Op == "<<-" {
+				line = strings.TrimLeft(line, "\t")
+			}
+			if line == delimiter {
+				break
+			}
+			body.WriteString(line + "\n")
+		}
+
+		word, err := hereDocWord(body.String(), redirect.Target.HasQuotes())
+		if err != nil {
+			return err
+		}
+		redirect.Body = word
+	}
+
+	l.hereDocs = nil
+	return nil
+}
+
+func missingDelimiter(redirect *Redirect) error {
+	return fmt.Errorf("%w while looking for here-document delimiter '%s'", ErrIncomplete, redirect.Target.Literal())
+}
+
+// hereDocWord returns the body of a here-document as a word. If any part of
+// the delimiter was quoted the body is taken literally, otherwise it is
+// expanded like the inside of double quotes with '"' as an ordinary
+// character.
+func hereDocWord(body string, quoted bool) (*Word, error) {
+	if quoted {
+		return &Word{Parts: []WordPart{&Literal{Text: body, Quoted: true}}}, nil
+	}
+
+	l := NewLexer(body)
+	parts, err := l.readQuotedParts(func(c byte) bool { return false }, "\\$`")
+	if err != nil {
+		return nil, err
+	}
+	return &Word{Parts: []WordPart{&DoubleQuoted{Parts: parts}}}, nil
+}
+
+func isRedirection(text string) bool {
+	_, ok := REDIRECTION_OPS[strings.TrimLeft(text, "0123456789")]
+	return ok
+}
+
+// isBlank reports whether c separates words without ending a command.
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+// isMetachar reports whether c ends an unquoted word.
+func isMetachar(c byte) bool {
+	return isBlank(c) || strings.IndexByte("\n|&;<>()", c) != -1
+}
+
+// readWord reads an unquoted word up to the next metacharacter. A
+// metacharacter followed by '(' starts a process substitution that is part
+// of the word.
+func (l *Lexer) readWord() (*Word, error) {
+	word := &Word{}
+
+	for {
+		parts, err := l.readParts(isMetachar)
+		if err != nil {
+			return nil, err
+		}
+		word.Parts = append(word.Parts, parts.Parts...)
+
+		if !l.atProcessSub() {
+			return word, nil
+		}
+
+		output := l.input[l.pos] == '>'
+		sub, err := l.readCommandSub()
+		if err != nil {
+			return nil, err
+		}
+		word.Parts = append(word.Parts, &ProcessSub{Output: output, Source: sub.Source, List: sub.List})
+	}
+}
+
+// atProcessSub reports whether the input continues with <( or >(.
+func (l *Lexer) atProcessSub() bool {
+	rest := l.input[l.pos:]
+	return strings.HasPrefix(rest, "<(") || strings.HasPrefix(rest, ">(")
+}
+
+// readParts reads the parts of a word until an unquoted character for
+// which isEnd returns true or the end of the input.
+func (l *Lexer) readParts(isEnd func(c byte) bool) (*Word, error) {
+	word := &Word{}
 	var curr strings.Builder
 
-	inSingle, inDouble := false, false
+	flush := func() {
+		if curr.Len() > 0 {
+			word.Parts = append(word.Parts, &Literal{Text: curr.String()})
+			curr.Reset()
+		}
+	}
 
-	for i := 0; i < len(input); i++ {
-		c := input[i]
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
 
-		if c == '\'' && !inDouble {
-			inSingle = !inSingle
-			continue
+		switch {
+		case isEnd(c):
+			flush()
+			return word, nil
+		case strings.IndexByte("?*+@!", c) != -1 && l.pos+1 < len(l.input) && l.input[l.pos+1] == '(':
+			// An extglob group belongs to the word even though it can hold
+			// characters such as '|' and ')' that would otherwise end it
+			end := l.extglobEnd()
+			curr.WriteString(l.input[l.pos:end])
+			l.pos = end
+		case c == '\'':
+			flush()
+			text, err := l.readSingleQuoted()
+			if err != nil {
+				return nil, err
+			}
+			word.Parts = append(word.Parts, &Literal{Text: text, Quoted: true})
+		case strings.HasPrefix(l.input[l.pos:], "$'"):
+			flush()
+			l.pos++
+			text, err := l.readANSIQuoted()
+			if err != nil {
+				return nil, err
+			}
+			word.Parts = append(word.Parts, &Literal{Text: text, Quoted: true})
+		case c == '"' || strings.HasPrefix(l.input[l.pos:], `$"`):
+			// $"..." would be translated using the current locale, which
+			// leaves it the same as "..."
+			flush()
+			if c == '$' {
+				l.pos++
+			}
+			part, err := l.readDoubleQuoted()
+			if err != nil {
+				return nil, err
+			}
+			word.Parts = append(word.Parts, part)
+		case c == '$' && isParamStart(l.input, l.pos+1):
+			flush()
+			param, err := l.readParam()
+			if err != nil {
+				return nil, err
+			}
+			word.Parts = append(word.Parts, param)
+		case strings.HasPrefix(l.input[l.pos:], "$(("):
+			flush()
+			l.pos += 3
+			expr, err := l.readArith()
+			if err != nil {
+				return nil, err
+			}
+			word.Parts = append(word.Parts, &ArithExp{Expr: expr})
+		case c == '$' && l.pos+1 < len(l.input) && l.input[l.pos+1] == '(':
+			flush()
+			sub, err := l.readCommandSub()
+			if err != nil {
+				return nil, err
+			}
+			word.Parts = append(word.Parts, sub)
+		case c == '`':
+			flush()
+			sub, err := l.readBackquoted()
+			if err != nil {
+				return nil, err
+			}
+			word.Parts = append(word.Parts, sub)
+		case c == '\\' && l.pos+1 == len(l.input):
+			// The command continues on the next line
+			return nil, fmt.Errorf("syntax error: %w", ErrIncomplete)
+		case c == '\\' && l.input[l.pos+1] == '\n':
+			// An escaped newline is removed, joining the two lines
+			l.pos += 2
+		case c == '\\':
+			// Outside quotes: escape anything
+			flush()
+			word.Parts = append(word.Parts, &Literal{Text: l.input[l.pos+1 : l.pos+2], Quoted: true})
+			l.pos += 2
+		default:
+			curr.WriteByte(c)
+			l.pos++
+		}
+	}
+
+	flush()
+	return word, nil
+}
+
+// extglobEnd returns the position just after the ')' that closes the
+// extglob group at the current position, or just after the character in
+// front of the '(' if the group is never closed.
+func (l *Lexer) extglobEnd() int {
+	depth := 0
+	for i := l.pos + 2; i < len(l.input); i++ {
+		switch l.input[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return i + 1
+			}
+			depth--
 		}
-		if c == '"' && !inSingle {
-			inDouble = !inDouble
+	}
+	return l.pos + 1
+}
+
+func (l *Lexer) readSingleQuoted() (string, error) {
+	end := strings.IndexByte(l.input[l.pos+1:], '\'')
+	if end == -1 {
+		return "", fmt.Errorf("missing closing quote")
+	}
+
+	text := l.input[l.pos+1 : l.pos+1+end]
+	l.pos += end + 2
+	return text, nil
+}
+
+// readANSIQuoted reads a $'...' string, starting at the opening quote, in
+// which backslash escapes are replaced as in C.
+func (l *Lexer) readANSIQuoted() (string, error) {
+	var sb strings.Builder
+
+	for i := l.pos + 1; i < len(l.input); i++ {
+		c := l.input[i]
+
+		if c == '\'' {
+			l.pos = i + 1
+			return sb.String(), nil
+		}
+
+		if c != '\\' || i+1 == len(l.input) {
+			sb.WriteByte(c)
 			continue
 		}
 
-		if c == '\\' && i+1 < len(input) {
-			next := input[i+1]
+		i++
+		if value, ok := ANSI_ESCAPES[l.input[i]]; ok {
+			sb.WriteByte(value)
+			continue
+		}
 
-			// Outside quotes: escape anything
-			if !inSingle && !inDouble {
-				curr.WriteByte(next)
-				i++
+		switch esc := l.input[i]; esc {
+		case 'x', 'u', 'U':
+			maxDigits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[esc]
+			digits := countDigits(l.input[i+1:], 16, maxDigits)
+			if digits == 0 {
+				sb.WriteString(l.input[i-1 : i+1])
 				continue
 			}
 
-			// Inside double quotes: only escape specific chars
-			if inDouble && (next == '\\' || next == '$' || next == '"') {
-				curr.WriteByte(next)
+			value, _ := strconv.ParseUint(l.input[i+1:i+1+digits], 16, 32)
+			if esc == 'x' {
+				sb.WriteByte(byte(value))
+			} else {
+				sb.WriteRune(rune(value))
+			}
+			i += digits
+
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			digits := countDigits(l.input[i:], 8, 3)
+			value, _ := strconv.ParseUint(l.input[i:i+digits], 8, 32)
+			sb.WriteByte(byte(value))
+			i += digits - 1
+
+		case 'c':
+			// \cX is the control character Ctrl+X
+			if i+1 < len(l.input) {
 				i++
-				continue
+				sb.WriteByte(l.input[i] & 0x1f)
+			}
+
+		default:
+			sb.WriteString(l.input[i-1 : i+1])
+		}
+	}
+
+	return "", fmt.Errorf("missing closing quote")
+}
+
+// countDigits returns how many of the first maxDigits bytes of s are
+// digits in base.
+func countDigits(s string, base, maxDigits int) int {
+	n := 0
+	for n < len(s) && n < maxDigits {
+		if digit := digitValue(s[n], base); digit < 0 || digit >= base {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+func (l *Lexer) readDoubleQuoted() (*DoubleQuoted, error) {
+	l.pos++
+
+	parts, err := l.readQuotedParts(func(c byte) bool { return c == '"' }, "\\$`\"")
+	if err != nil {
+		return nil, err
+	}
+	if l.pos == len(l.input) {
+		return nil, fmt.Errorf("missing closing quote")
+	}
+	l.pos++
+
+	if len(parts) == 0 {
+		parts = append(parts, &Literal{Quoted: true})
+	}
+	return &DoubleQuoted{Parts: parts}, nil
+}
+
+// readQuotedParts reads the parts of quoted text until a character for
+// which isEnd returns true or the end of the input. Only expansions and a
+// backslash followed by a newline or one of the characters in escapes are
+// special.
+func (l *Lexer) readQuotedParts(isEnd func(c byte) bool, escapes string) ([]WordPart, error) {
+	var parts []WordPart
+	var curr strings.Builder
+
+	flush := func() {
+		if curr.Len() > 0 {
+			parts = append(parts, &Literal{Text: curr.String(), Quoted: true})
+			curr.Reset()
+		}
+	}
+
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+
+		if isEnd(c) {
+			break
+		}
+
+		if c == '$' && isParamStart(l.input, l.pos+1) {
+			flush()
+			param, err := l.readParam()
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, param)
+			continue
+		}
+
+		if strings.HasPrefix(l.input[l.pos:], "$((") {
+			flush()
+			l.pos += 3
+			expr, err := l.readArith()
+			if err != nil {
+				return nil, err
 			}
+			parts = append(parts, &ArithExp{Expr: expr})
+			continue
 		}
 
-		// Handle token splitting
-		if c == ' ' && !inSingle && !inDouble {
-			if curr.Len() > 0 {
-				tokens = append(tokens, curr.String())
-				curr.Reset()
+		if (c == '$' && l.pos+1 < len(l.input) && l.input[l.pos+1] == '(') || c == '`' {
+			flush()
+
+			var sub *CommandSub
+			var err error
+			if c == '`' {
+				sub, err = l.readBackquoted()
+			} else {
+				sub, err = l.readCommandSub()
 			}
+			if err != nil {
+				return nil, err
+			}
+
+			parts = append(parts, sub)
 			continue
 		}
 
+		// Inside quotes: only escape specific chars, an escaped newline
+		// joins two lines
+		if c == '\\' && l.pos+1 < len(l.input) {
+			next := l.input[l.pos+1]
+			if next == '\n' {
+				l.pos += 2
+				continue
+			}
+			if strings.IndexByte(escapes, next) != -1 {
+				curr.WriteByte(next)
+				l.pos += 2
+				continue
+			}
+		}
+
 		curr.WriteByte(c)
+		l.pos++
 	}
 
-	if curr.Len() > 0 {
-		tokens = append(tokens, curr.String())
+	flush()
+	return parts, nil
+}
+
+// readCommandSub reads a $(...) command substitution, or the same form of
+// a process substitution, by parsing the commands that follow up to the
+// matching ')'.
+func (l *Lexer) readCommandSub() (*CommandSub, error) {
+	start := l.pos + 2
+
+	p := &Parser{lexer: &Lexer{input: l.input, pos: start}}
+	if err := p.advance(); err != nil {
+		return nil, err
 	}
 
-	if inSingle || inDouble {
-		return nil, fmt.Errorf("missing closing quote")
+	list, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOperator(")") {
+		return nil, fmt.Errorf("missing closing ')'")
 	}
 
-	if err := validateTokens(tokens); err != nil {
+	l.pos = p.lexer.pos
+	return &CommandSub{Source: l.input[start : l.pos-1], List: list}, nil
+}
+
+// readArith reads an arithmetic expression up to the "))" that closes it,
+// keeping track of any parentheses inside the expression.
+func (l *Lexer) readArith() (*Word, error) {
+	depth := 0
+	expr, err := l.readParts(func(c byte) bool {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return true
+			}
+			depth--
+		}
+		return false
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	return tokens, nil
+	if !strings.HasPrefix(l.input[l.pos:], "))") {
+		return nil, fmt.Errorf("missing closing '))'")
+	}
+	l.pos += 2
+	return expr, nil
 }
 
-func validateTokens(tokens []string) error {
-	for i, token := range tokens {
-		isLastToken := i == (len(tokens) - 1)
+// readBackquoted reads a `...` command substitution. Inside the backquotes
+// a backslash only escapes '$', '`' and '\'.
+func (l *Lexer) readBackquoted() (*CommandSub, error) {
+	var source strings.Builder
 
-		if token == "|" && (i == 0 || isLastToken) {
-			return fmt.Errorf("missing command before/after '|'")
+	for i := l.pos + 1; i < len(l.input); i++ {
+		c := l.input[i]
+
+		if c == '\\' && i+1 < len(l.input) && strings.IndexByte("$`\\", l.input[i+1]) != -1 {
+			source.WriteByte(l.input[i+1])
+			i++
+			continue
 		}
 
-		_, ok := REDIRECTION_OPS[token]
-		if ok && (isLastToken || tokens[i+1] == "|") {
-			return fmt.Errorf("expected file name after '%s'", token)
+		if c == '`' {
+			list, err := Parse(source.String())
+			if err != nil {
+				return nil, err
+			}
+
+			l.pos = i + 1
+			return &CommandSub{Source: source.String(), List: list}, nil
 		}
+
+		source.WriteByte(c)
 	}
-	return nil
+
+	return nil, fmt.Errorf("missing closing '`'")
+}
+
+func isParamStart(input string, pos int) bool {
+	if pos >= len(input) {
+		return false
+	}
+	c := input[pos]
+	return c == '{' || isSpecialParam(c) || isNameStart(c) || isDigit(c)
 }
 
+// isSpecialParam reports whether c names a special parameter: the status
+// of the last command '?', the number of positional parameters '#' or all
+// of them as '@' or '*'.
+func isSpecialParam(c byte) bool {
+	return c == '?' || c == '#' || c == '@' || c == '*'
+}
 
-func splitOnPipes(tokens []string) [][]string {
-	var pipelineTokens [][]string
-	var curr []string
+func isNameStart(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
 
-	for _, token := range tokens {
-		if token == "|" {
-			pipelineTokens = append(pipelineTokens, curr)
-			curr = nil
-			continue
+func isNameChar(c byte) bool {
+	return isNameStart(c) || ('0' <= c && c <= '9')
+}
+
+// readParam reads a '$' followed by the name of a parameter, either bare
+// as in $NAME or braced as in ${NAME}, ${NAME[1]}, ${#NAME} or ${NAME:-word}.
+func (l *Lexer) readParam() (*ParamExp, error) {
+	l.pos++
+
+	if l.input[l.pos] != '{' {
+		// Only a single digit can follow an unbraced '$', so $10 is $1
+		// followed by 0
+		if isDigit(l.input[l.pos]) {
+			l.pos++
+			return &ParamExp{Name: l.input[l.pos-1 : l.pos]}, nil
 		}
+		return &ParamExp{Name: l.readName()}, nil
+	}
 
-		curr = append(curr, token)
+	start := l.pos
+	l.pos++
+
+	param := &ParamExp{}
+
+	if l.pos+1 < len(l.input) && l.input[l.pos] == '#' && l.input[l.pos+1] != '}' {
+		param.Length = true
+		l.pos++
+	}
+
+	param.Name = l.readName()
+
+	if l.pos < len(l.input) && l.input[l.pos] == '[' {
+		end := strings.IndexByte(l.input[l.pos:], ']')
+		if end == -1 {
+			return nil, fmt.Errorf("missing closing ']'")
+		}
+		param.Index = l.input[l.pos+1 : l.pos+end]
+		l.pos += end + 1
+	}
+
+	if !param.Length {
+		param.Op = l.readParamOp()
 	}
 
-	if len(curr) != 0 {
-		pipelineTokens = append(pipelineTokens, curr)
+	if param.Op != "" {
+		isReplace := strings.HasPrefix(param.Op, "/")
+
+		word, err := l.readParts(func(c byte) bool { return c == '}' || (isReplace && c == '/') })
+		if err != nil {
+			return nil, err
+		}
+		param.Word = word
+
+		if isReplace && l.pos < len(l.input) && l.input[l.pos] == '/' {
+			l.pos++
+			if param.Replace, err = l.readParts(func(c byte) bool { return c == '}' }); err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	return pipelineTokens
-}
\ No newline at end of file
+	if l.pos >= len(l.input) {
+		return nil, fmt.Errorf("missing closing '}'")
+	}
+	if l.input[l.pos] != '}' || param.Name == "" {
+		end := strings.IndexByte(l.input[start:], '}')
+		if end == -1 {
+			return nil, fmt.Errorf("missing closing '}'")
+		}
+		return nil, fmt.Errorf("$%s: bad substitution", l.input[start:start+end+1])
+	}
+	l.pos++
+
+	return param, nil
+}
+
+// readParamOp reads the operator that follows the name in a braced
+// parameter expansion, preferring the longest match.
+func (l *Lexer) readParamOp() string {
+	for _, op := range PARAM_OPS {
+		if strings.HasPrefix(l.input[l.pos:], op) {
+			l.pos += len(op)
+			return op
+		}
+	}
+	return ""
+}
+
+// readName reads a special parameter such as '?', a name made of letters,
+// digits and underscores or the number of a positional parameter.
+func (l *Lexer) readName() string {
+	if l.pos < len(l.input) && isSpecialParam(l.input[l.pos]) {
+		l.pos++
+		return l.input[l.pos-1 : l.pos]
+	}
+
+	start := l.pos
+	for l.pos < len(l.input) && isNameChar(l.input[l.pos]) {
+		l.pos++
+	}
+	return l.input[start:l.pos]
+}
@@ -91,6 +91,16 @@ func TestTokenize(t *testing.T) {
 			input:    `echo "example\"insidequotes"hello\"`,
 			expected: []string{"echo", `example"insidequoteshello"`},
 		},
+		{
+			name:     "backslash newline between words is removed",
+			input:    "echo one \\\n  two",
+			expected: []string{"echo", "one", "two"},
+		},
+		{
+			name:     "backslash newline inside word joins lines",
+			input:    "ec\\\nho on\\\ne",
+			expected: []string{"echo", "one"},
+		},
 		{
 			name:     "piped command",
 			input:    `cat README.md | grep 'bitbash'`,
@@ -106,14 +116,139 @@ func TestTokenize(t *testing.T) {
 			input:    "cat README.md > file.txt 2> errors.txt",
 			expected: []string{"cat", "README.md", ">", "file.txt", "2>", "errors.txt"},
 		},
+		{
+			name:     "redirection without spaces",
+			input:    "echo hi>out.txt",
+			expected: []string{"echo", "hi", ">", "out.txt"},
+		},
+		{
+			name:     "pipe without spaces",
+			input:    "ls|wc -l",
+			expected: []string{"ls", "|", "wc", "-l"},
+		},
+		{
+			name:     "tabs separate words",
+			input:    "echo\ta \t b",
+			expected: []string{"echo", "a", "b"},
+		},
+		{
+			name:     "longest operator",
+			input:    "make&>>build.log;cat<in 2>&1",
+			expected: []string{"make", "&>>", "build.log", ";", "cat", "<", "in", "2>&", "1"},
+		},
+		{
+			name:     "file descriptor only at start of word",
+			input:    "echo 2 a2>x",
+			expected: []string{"echo", "2", "a2", ">", "x"},
+		},
+		{
+			name:     "preserve empty quotes",
+			input:    `echo '' ""`,
+			expected: []string{"echo", "", ""},
+		},
+		{
+			name:     "ansi-c quoting",
+			input:    `echo $'a\tb' $'\x1b[31m' $'\u00e9' $'\101\102' $'it\'s'`,
+			expected: []string{"echo", "a\tb", "\x1b[31m", "é", "AB", "it's"},
+		},
+		{
+			name:     "ansi-c quoting control and unknown escapes",
+			input:    `echo $'\cA\e\q' $'\x'`,
+			expected: []string{"echo", "\x01\x1b\\q", `\x`},
+		},
+		{
+			name:     "ansi-c quoting is literal in double quotes",
+			input:    `echo "$'\t'"`,
+			expected: []string{"echo", `$'\t'`},
+		},
+		{
+			name:     "locale quoting",
+			input:    `echo $"hello  world"`,
+			expected: []string{"echo", "hello  world"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tokens, _ := Tokenize(tc.input)
+			res := tokenStrings(tokens)
+			if !reflect.DeepEqual(res, tc.expected) {
+				t.Fatalf("expected: %#v, got: %#v", tc.expected, res)
+			}
+		})
+	}
+}
+
+func TestTokenizeQuotedOperators(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected []TokenKind
+	}{
+		{
+			name:     "unquoted pipe",
+			input:    `echo a | cat`,
+			expected: []TokenKind{TOKEN_WORD, TOKEN_WORD, TOKEN_OPERATOR, TOKEN_WORD},
+		},
+		{
+			name:     "double quoted pipe",
+			input:    `echo "|" cat`,
+			expected: []TokenKind{TOKEN_WORD, TOKEN_WORD, TOKEN_WORD},
+		},
+		{
+			name:     "single quoted redirection",
+			input:    `echo '>' file`,
+			expected: []TokenKind{TOKEN_WORD, TOKEN_WORD, TOKEN_WORD},
+		},
+		{
+			name:     "escaped redirection",
+			input:    `echo \>> file`,
+			expected: []TokenKind{TOKEN_WORD, TOKEN_WORD, TOKEN_OPERATOR, TOKEN_WORD},
+		},
+		{
+			name:     "process substitution",
+			input:    `diff <(sort a) >(cat)<in`,
+			expected: []TokenKind{TOKEN_WORD, TOKEN_WORD, TOKEN_WORD, TOKEN_OPERATOR, TOKEN_WORD},
+		},
+		{
+			name:     "quoted process substitution",
+			input:    `echo "<(a)" \<(a)`,
+			expected: []TokenKind{TOKEN_WORD, TOKEN_WORD, TOKEN_WORD, TOKEN_OPERATOR, TOKEN_WORD, TOKEN_OPERATOR},
+		},
+		{
+			name:     "quoted metacharacters without spaces",
+			input:    `echo 'a|b'>"c;d"`,
+			expected: []TokenKind{TOKEN_WORD, TOKEN_WORD, TOKEN_OPERATOR, TOKEN_WORD},
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			res, _ := Tokenize(tc.input)
+			tokens, err := Tokenize(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			var res []TokenKind
+			for _, token := range tokens {
+				res = append(res, token.Kind)
+			}
+
 			if !reflect.DeepEqual(res, tc.expected) {
 				t.Fatalf("expected: %#v, got: %#v", tc.expected, res)
 			}
 		})
 	}
 }
+
+func tokenStrings(tokens []Token) []string {
+	var res []string
+	for _, token := range tokens {
+		if token.Kind == TOKEN_WORD {
+			res = append(res, token.Word.Literal())
+		} else {
+			res = append(res, token.Text)
+		}
+	}
+	return res
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+type Variable struct {
+	Value    string
+	Exported bool
+	ReadOnly bool
+}
+
+// VariablesFromEnviron returns a variable table holding every variable in
+// the environment of the shell process, all marked as exported.
+func VariablesFromEnviron() map[string]*Variable {
+	vars := make(map[string]*Variable)
+	for _, entry := range os.Environ() {
+		if name, value, ok := strings.Cut(entry, "="); ok {
+			vars[name] = &Variable{Value: value, Exported: true}
+		}
+	}
+	return vars
+}
+
+func IsValidName(name string) bool {
+	if name == "" || !isNameStart(name[0]) {
+		return false
+	}
+	for i := 1; i < len(name); i++ {
+		if !isNameChar(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (cfg *Config) GetVar(name string) (string, bool) {
+	variable, ok := cfg.Variables[name]
+	if !ok {
+		return "", false
+	}
+	return variable.Value, true
+}
+
+func (cfg *Config) SetVar(name, value string) error {
+	variable, ok := cfg.Variables[name]
+	if !ok {
+		cfg.Variables[name] = &Variable{Value: value}
+		return nil
+	}
+
+	if variable.ReadOnly {
+		return fmt.Errorf("%s: readonly variable", name)
+	}
+	variable.Value = value
+	return nil
+}
+
+func (cfg *Config) UnsetVar(name string) error {
+	if variable, ok := cfg.Variables[name]; ok && variable.ReadOnly {
+		return fmt.Errorf("%s: cannot unset: readonly variable", name)
+	}
+	delete(cfg.Variables, name)
+	return nil
+}
+
+// SetTempVar assigns value to name as an exported variable until the
+// returned function is called to restore its previous state.
+func (cfg *Config) SetTempVar(name, value string) (restore func(), err error) {
+	prev, existed := cfg.Variables[name]
+	if existed && prev.ReadOnly {
+		return nil, fmt.Errorf("%s: readonly variable", name)
+	}
+
+	cfg.Variables[name] = &Variable{Value: value, Exported: true}
+
+	return func() {
+		if existed {
+			cfg.Variables[name] = prev
+		} else {
+			delete(cfg.Variables, name)
+		}
+	}, nil
+}
+
+// DeclareLocal makes name a variable of the innermost function call,
+// hiding any variable of the same name until the function returns. Without
+// a value it is empty, or keeps its value if it is already local.
+func (cfg *Config) DeclareLocal(name, value string, hasValue bool) error {
+	prev, existed := cfg.Variables[name]
+	if existed && prev.ReadOnly {
+		return fmt.Errorf("%s: readonly variable", name)
+	}
+
+	scope := cfg.Locals[len(cfg.Locals)-1]
+	if _, ok := scope[name]; !ok {
+		scope[name] = prev
+		local := &Variable{}
+		if existed {
+			local.Exported = prev.Exported
+		}
+		cfg.Variables[name] = local
+	}
+
+	if hasValue {
+		cfg.Variables[name].Value = value
+	}
+	return nil
+}
+
+// popLocals removes the local variables of the innermost function call,
+// bringing back the variables they hid.
+func (cfg *Config) popLocals() {
+	scope := cfg.Locals[len(cfg.Locals)-1]
+	cfg.Locals = cfg.Locals[:len(cfg.Locals)-1]
+
+	for name, prev := range scope {
+		if prev == nil {
+			delete(cfg.Variables, name)
+		} else {
+			cfg.Variables[name] = prev
+		}
+	}
+}
+
+// Environ returns the exported variables in the "NAME=value" form used as
+// the environment of child processes.
+func (cfg *Config) Environ() []string {
+	var env []string
+	for _, name := range slices.Sorted(maps.Keys(cfg.Variables)) {
+		if variable := cfg.Variables[name]; variable.Exported {
+			env = append(env, name+"="+variable.Value)
+		}
+	}
+	return env
+}
+
+// QuoteValue wraps value in double quotes escaping any characters that
+// would otherwise be expanded so it can be read back by the shell.
+func QuoteValue(value string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '"', '\\', '$', '`':
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(value[i])
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
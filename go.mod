@@ -4,4 +4,4 @@ go 1.24.0
 
 require golang.org/x/term v0.30.0
 
-require golang.org/x/sys v0.31.0 // indirect
+require golang.org/x/sys v0.31.0